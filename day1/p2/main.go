@@ -1,8 +1,10 @@
 package main
 
 import (
+	"adventofcode-2023/lib/queue"
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -12,7 +14,25 @@ import (
 	"unsafe"
 )
 
+var wordsFile = ""
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	patterns := defaultPatterns()
+	if wordsFile != "" {
+		f, err := os.Open(wordsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		patterns, err = readPatterns(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", wordsFile, err)
+		}
+	}
+
+	m := NewMatcher(patterns)
+
 	sum := 0
 	lineNo := 0
 
@@ -30,8 +50,7 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 			return fmt.Errorf("%04d: line too long", lineNo)
 		}
 
-		a := searchFirstDigit(s)
-		b := searchLastDigit(s)
+		a, b, _ := m.FirstLast(s)
 		v := a*10 + b
 		sum += v
 
@@ -44,63 +63,169 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	return nil
 }
 
-var digits = [][]byte{
-	[]byte("\x00"),
-	[]byte("one"),
-	[]byte("two"),
-	[]byte("three"),
-	[]byte("four"),
-	[]byte("five"),
-	[]byte("six"),
-	[]byte("seven"),
-	[]byte("eight"),
-	[]byte("nine"),
+// Pattern is the word spelling the digit
+type Pattern struct {
+	word []byte
+	val  int
 }
 
-func searchFirstDigit(s []byte) int {
-	val := 0
-	minP := len(s)
+var digits = []string{
+	"one",
+	"two",
+	"three",
+	"four",
+	"five",
+	"six",
+	"seven",
+	"eight",
+	"nine",
+}
 
-	for _, c := range []byte("0123456789") {
-		p := bytes.IndexByte(s, c)
-		if p != -1 && p < minP {
-			minP = p
-			val = int(c - '0')
-		}
+// digitPatterns returns the patterns of the digit characters
+func digitPatterns() []Pattern {
+	patterns := make([]Pattern, 0, 10)
+	for c := byte('0'); c <= '9'; c++ {
+		patterns = append(patterns, Pattern{[]byte{c}, int(c - '0')})
 	}
+	return patterns
+}
 
-	for v, digit := range digits {
-		p := bytes.Index(s, digit)
-		if p != -1 && p < minP {
-			minP = p
-			val = v
+func defaultPatterns() []Pattern {
+	patterns := digitPatterns()
+	for k, w := range digits {
+		patterns = append(patterns, Pattern{[]byte(w), k + 1})
+	}
+	return patterns
+}
+
+// readPatterns reads the lines `word digit` in any encoding. The empty lines and the
+// lines starting with '#' are skipped
+func readPatterns(r io.Reader) ([]Pattern, error) {
+	patterns := digitPatterns()
+
+	sc := bufio.NewScanner(r)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%d: want `word digit`, got %q", lineNo, line)
+		}
+
+		v, err := strconv.Atoi(string(fields[1]))
+		if err != nil || v < 0 || v > 9 {
+			return nil, fmt.Errorf("%d: invalid digit %q", lineNo, fields[1])
 		}
+
+		patterns = append(patterns, Pattern{bytes.Clone(fields[0]), v})
 	}
 
-	return val
+	return patterns, sc.Err()
+}
+
+type node struct {
+	next [256]int32
+	fail int32
+	out  int32 // the longest pattern ending at the node or -1
+	link int32 // the nearest node by fail links having a pattern or 0
 }
 
-func searchLastDigit(s []byte) int {
-	val := 0
-	maxP := -1
+// Matcher is the Aho-Corasick automaton which finds all the patterns in one pass
+// including the overlapped ones (e.g. "twone")
+type Matcher struct {
+	nodes    []node
+	patterns []Pattern
+}
+
+func NewMatcher(patterns []Pattern) *Matcher {
+	m := &Matcher{
+		nodes:    []node{{out: -1}},
+		patterns: patterns,
+	}
 
-	for _, c := range []byte("0123456789") {
-		p := bytes.LastIndexByte(s, c)
-		if p != -1 && p > maxP {
-			maxP = p
-			val = int(c - '0')
+	// trie, 0 is the absent edge since the root is never a child
+	for k, p := range patterns {
+		cur := int32(0)
+		for _, c := range p.word {
+			if m.nodes[cur].next[c] == 0 {
+				m.nodes = append(m.nodes, node{out: -1})
+				m.nodes[cur].next[c] = int32(len(m.nodes) - 1)
+			}
+			cur = m.nodes[cur].next[c]
+		}
+		if m.nodes[cur].out == -1 {
+			m.nodes[cur].out = int32(k)
 		}
 	}
 
-	for v, digit := range digits {
-		p := bytes.LastIndex(s, digit)
-		if p != -1 && p > maxP {
-			maxP = p
-			val = v
+	// fail links by BFS, the missing edges are replaced with the edges of the fail node
+	var q queue.Queue[int32]
+	for c := range m.nodes[0].next {
+		if child := m.nodes[0].next[c]; child != 0 {
+			q.Push(child)
+		}
+	}
+
+	for q.Size() > 0 {
+		cur := q.Pop()
+		fail := m.nodes[cur].fail
+
+		if m.nodes[fail].out != -1 {
+			m.nodes[cur].link = fail
+		} else {
+			m.nodes[cur].link = m.nodes[fail].link
+		}
+
+		for c := range m.nodes[cur].next {
+			child := m.nodes[cur].next[c]
+			if child == 0 {
+				m.nodes[cur].next[c] = m.nodes[fail].next[c]
+				continue
+			}
+			m.nodes[child].fail = m.nodes[fail].next[c]
+			q.Push(child)
 		}
 	}
 
-	return val
+	return m
+}
+
+// Scan calls f for each pattern found in s with its start position
+func (m *Matcher) Scan(s []byte, f func(start int, p *Pattern)) {
+	cur := int32(0)
+	for i, c := range s {
+		cur = m.nodes[cur].next[c]
+		for n := cur; n != 0; n = m.nodes[n].link {
+			if out := m.nodes[n].out; out != -1 {
+				p := &m.patterns[out]
+				f(i-len(p.word)+1, p)
+			}
+		}
+	}
+}
+
+// FirstLast returns the values of the first and the last patterns in s. The first
+// pattern starts first, the last one ends last, the longest pattern wins the ties
+// (e.g. "восемь" contains "семь")
+func (m *Matcher) FirstLast(s []byte) (int, int, bool) {
+	first, firstLen := -1, 0
+	last, lastLen := -1, 0
+	var firstVal, lastVal int
+
+	m.Scan(s, func(start int, p *Pattern) {
+		n := len(p.word)
+		if first == -1 || start < first || start == first && n > firstLen {
+			first, firstLen, firstVal = start, n, p.val
+		}
+		if end := start + n; end > last || end == last && n > lastLen {
+			last, lastLen, lastVal = end, n, p.val
+		}
+	})
+
+	return firstVal, lastVal, first != -1
 }
 
 func run(r io.Reader, w io.Writer) (err error) {
@@ -117,6 +242,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&wordsFile, "words", wordsFile, "file with the lines \"word digit\" spelling the digits (default English)")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -62,3 +64,88 @@ zoneight234
 		})
 	}
 }
+
+func Test_run_words(t *testing.T) {
+	tests := []struct {
+		name    string
+		words   string
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{"overlaps", "", "twone\neightwo\noneight\nsevenine", `21 + 82 + 18 + 79`, false},
+		{"no digits", "", "abc\nxyz", `0`, false},
+		{"russian", "digits_ru.txt", "дваодин3\nxсемьвосемь\nодиннадцать\nтридевять\nнольпять", `23 + 78 + 11 + 39 + 5`, false},
+		{"russian ignores english", "digits_ru.txt", "one2три", `23`, false},
+		{"no file", "none.txt", "one", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wordsFile = tt.words
+			defer func() { wordsFile = "" }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotW, want := strings.TrimSpace(w.String()), sumExpr(tt.wantW); gotW != want {
+				t.Errorf("run() = %v, want %v (%s)", gotW, want, tt.wantW)
+			}
+		})
+	}
+}
+
+// sumExpr returns the sum of the numbers separated by " + "
+func sumExpr(expr string) string {
+	sum := 0
+	for _, s := range strings.Split(expr, " + ") {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			panic(err)
+		}
+		sum += v
+	}
+	return strconv.Itoa(sum)
+}
+
+func Test_readPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"comments", "# digits\n\nuno 1\ndos 2\n", 12, false},
+		{"no digit", "uno", 0, true},
+		{"bad digit", "diez 10", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPatterns(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("readPatterns() = %d patterns, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_Scan(t *testing.T) {
+	m := NewMatcher([]Pattern{{[]byte("he"), 1}, {[]byte("she"), 2}, {[]byte("his"), 3}, {[]byte("hers"), 4}})
+
+	var got []string
+	m.Scan([]byte("ushers"), func(start int, p *Pattern) {
+		got = append(got, fmt.Sprintf("%s@%d", p.word, start))
+	})
+
+	want := "she@1 he@2 hers@2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Scan() = %v, want %v", got, want)
+	}
+}
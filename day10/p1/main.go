@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -37,6 +38,14 @@ func (p Point) Valid(n, m int) bool {
 	return 0 <= p.i && p.i < n && 0 <= p.j && p.j < m
 }
 
+func (p Point) Reverse() Point {
+	return Point{-1 * p.i, -1 * p.j}
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%d,%d)", p.i, p.j)
+}
+
 var (
 	North = Point{-1, 0}
 	South = Point{1, 0}
@@ -95,62 +104,194 @@ func getToDir(c byte, fromDir Point) (Point, bool) {
 	return Point{}, false
 }
 
-type Way struct {
-	point   Point
-	fromDir Point
+var (
+	ErrStartNotFound  = errors.New("start not found")
+	ErrAmbiguousStart = errors.New("ambiguous start")
+	ErrLoopNotClosed  = errors.New("loop not closed")
+)
+
+// DanglingPipeError is returned if the pipe at the point leads nowhere
+type DanglingPipeError struct {
+	Point Point
 }
 
-func doStep(plane [][]byte, way Way) (Way, bool) {
-	toDir, ok := getToDir(plane[way.point.i][way.point.j], way.fromDir)
-	if ok {
-		way.point = way.point.Add(toDir)
-		way.fromDir = Point{-1 * toDir.i, -1 * toDir.j}
+func (e *DanglingPipeError) Error() string {
+	return fmt.Sprintf("dangling pipe at %v", e.Point)
+}
+
+const pipes = "|-LJ7F"
+
+// getPipeDirs returns two directions connected by the pipe c
+func getPipeDirs(c byte) (Point, Point, bool) {
+	switch c {
+	case '|':
+		return North, South, true
+	case '-':
+		return East, West, true
+	case 'L':
+		return North, East, true
+	case 'J':
+		return North, West, true
+	case '7':
+		return South, West, true
+	case 'F':
+		return South, East, true
 	}
-	return way, false
+	return Point{}, Point{}, false
 }
 
-func searchForBeast(plane [][]byte) int {
+// isConnected reports whether the neighbour of p in the direction dir has a pipe
+// connected to p
+func isConnected(plane [][]byte, p, dir Point) bool {
 	n := len(plane)
 	m := len(plane[0])
 
-	start := getStart(plane)
-	if debugEnable {
-		log.Println("start:", start)
+	neig := p.Add(dir)
+	if !neig.Valid(n, m) {
+		return false
+	}
+
+	_, ok := getToDir(plane[neig.i][neig.j], dir.Reverse())
+	return ok
+}
+
+// followLoop follows the pipes from the start point until it comes back. It returns the
+// loop points beginning from the start one
+func followLoop(plane [][]byte, start Point) ([]Point, error) {
+	n := len(plane)
+	m := len(plane[0])
+
+	toDir, _, ok := getPipeDirs(plane[start.i][start.j])
+	if !ok {
+		return nil, &DanglingPipeError{start}
+	}
+
+	loop := []Point{start}
+
+	// each pipe connects exactly two neighbours, so the way can't come to a loop
+	// without the start point
+	for p := start; ; {
+		next := p.Add(toDir)
+		if !next.Valid(n, m) {
+			return nil, &DanglingPipeError{p}
+		}
+
+		fromDir := toDir.Reverse()
+
+		if next == start {
+			if _, ok := getToDir(plane[start.i][start.j], fromDir); !ok {
+				return nil, &DanglingPipeError{p}
+			}
+			return loop, nil
+		}
+
+		toDir, ok = getToDir(plane[next.i][next.j], fromDir)
+		if !ok {
+			return nil, &DanglingPipeError{p}
+		}
+
+		loop = append(loop, next)
+		p = next
 	}
+}
+
+// openEnd follows the pipes from the start in the direction toDir and returns the last
+// pipe of the chain, the one which leads nowhere
+func openEnd(plane [][]byte, start, toDir Point) Point {
+	n := len(plane)
+	m := len(plane[0])
 
-	way := [2]Way{}
+	for p := start; ; {
+		next := p.Add(toDir)
+		if !next.Valid(n, m) || next == start {
+			return p
+		}
 
-	k := 0
-	for _, toDir := range []Point{North, South, West, East} {
-		if k == 2 {
-			break
+		dir, ok := getToDir(plane[next.i][next.j], toDir.Reverse())
+		if !ok {
+			return p
 		}
 
-		fromDir := Point{-1 * toDir.i, -1 * toDir.j}
-		if p := start.Add(toDir); p.Valid(n, m) {
-			c := plane[p.i][p.j]
-			if _, ok := getToDir(c, fromDir); ok {
-				way[k] = Way{fromDir: fromDir, point: p}
-				k++
+		p, toDir = next, dir
+	}
+}
+
+// inferStart infers the pipe shape under S and replaces S with it. It returns the loop
+// beginning from the start point
+func inferStart(plane [][]byte) ([]Point, error) {
+	start := getStart(plane)
+	if start.i == -1 {
+		return nil, ErrStartNotFound
+	}
+
+	var (
+		loops    [][]Point
+		shapes   []byte
+		firstErr error
+	)
+
+	for k := range pipes {
+		c := pipes[k]
+		dir1, dir2, _ := getPipeDirs(c)
+		if !isConnected(plane, start, dir1) || !isConnected(plane, start, dir2) {
+			continue
+		}
+
+		plane[start.i][start.j] = c
+		loop, err := followLoop(plane, start)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
 			}
+			continue
 		}
+
+		loops = append(loops, loop)
+		shapes = append(shapes, c)
 	}
 
-	if k != 2 {
-		panic("not found ways from start")
+	if debugEnable {
+		log.Printf("start %v may be %q", start, shapes)
 	}
 
-	count := 1
-	for way[0].point != way[1].point && count < n*m/2 {
-		if debugEnable {
-			log.Printf("%d: %v %v", count, way[0], way[1])
+	switch len(shapes) {
+	case 0:
+		plane[start.i][start.j] = 'S'
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		// less than two neighbours are connected to the start, so the pipes from it
+		// make an open chain
+		var connected []Point
+		for _, dir := range []Point{North, East, South, West} {
+			if isConnected(plane, start, dir) {
+				connected = append(connected, dir)
+			}
+		}
+		if len(connected) == 0 {
+			return nil, fmt.Errorf("%w: start %v has no connected pipes", ErrLoopNotClosed, start)
 		}
-		way[0], _ = doStep(plane, way[0])
-		way[1], _ = doStep(plane, way[1])
-		count++
+		return nil, fmt.Errorf("%w: open end at %v", ErrLoopNotClosed, openEnd(plane, start, connected[0]))
+	case 1:
+		plane[start.i][start.j] = shapes[0]
+		return loops[0], nil
+	default:
+		plane[start.i][start.j] = 'S'
+		return nil, fmt.Errorf("%w: S at %v may be any of %q", ErrAmbiguousStart, start, shapes)
 	}
+}
 
-	return count
+func searchForBeast(plane [][]byte) (int, error) {
+	loop, err := inferStart(plane)
+	if err != nil {
+		return 0, err
+	}
+
+	if debugEnable {
+		log.Println("loop:", loop)
+	}
+
+	return len(loop) / 2, nil
 }
 
 func makeMatrix(n, m int) [][]int {
@@ -168,7 +309,10 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 		return err
 	}
 
-	count := searchForBeast(plane)
+	count, err := searchForBeast(plane)
+	if err != nil {
+		return err
+	}
 
 	fmt.Fprintln(bw, count)
 	return nil
@@ -180,7 +324,7 @@ func readPlane(r io.Reader) ([][]byte, error) {
 		return nil, err
 	}
 
-	bytes.TrimSpace(buf)
+	buf = bytes.TrimSpace(buf)
 	plane := bytes.Split(buf, []byte("\n"))
 	for i := range plane {
 		plane[i] = bytes.TrimSpace(plane[i])
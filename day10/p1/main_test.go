@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"strings"
 	"testing"
@@ -79,3 +80,87 @@ LJ.LJ`)},
 		})
 	}
 }
+
+func Test_inferStart(t *testing.T) {
+	tests := []struct {
+		name      string
+		plane     string
+		wantStart byte
+		wantLen   int
+		wantErr   error
+	}{
+		{"F", "S-7\n|.|\nL-J", 'F', 8, nil},
+		{"J", "F-7\n|.|\nL-S", 'J', 8, nil},
+		{"extra neighbours", "7|7\n-S7\n.|.\nL-J", 0, 0, &DanglingPipeError{}},
+		{"ambiguous", "F-7.\n|FS7\n||||\nLJLJ", 0, 0, ErrAmbiguousStart},
+		{"not closed", "S-7\n..|\nL-J", 0, 0, ErrLoopNotClosed},
+		{"lonely start", ".S.\n...", 0, 0, ErrLoopNotClosed},
+		{"dangling", "S-7\n|.|\nL-.", 0, 0, &DanglingPipeError{Point{2, 1}}},
+		{"no start", "F-7\n|.|\nL-J", 0, 0, ErrStartNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plane, err := readPlane(strings.NewReader(tt.plane))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loop, err := inferStart(plane)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("inferStart() error = %v", err)
+				}
+			case *DanglingPipeError:
+				var got *DanglingPipeError
+				if !errors.As(err, &got) {
+					t.Fatalf("inferStart() error = %v, want %T", err, tt.wantErr)
+				}
+				if want.Point != (Point{}) && got.Point != want.Point {
+					t.Errorf("inferStart() error = %v, want %v", err, want)
+				}
+				return
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("inferStart() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			start := loop[0]
+			if got := plane[start.i][start.j]; got != tt.wantStart {
+				t.Errorf("inferStart() start = %c, want %c", got, tt.wantStart)
+			}
+			if len(loop) != tt.wantLen {
+				t.Errorf("inferStart() loop length = %d, want %d", len(loop), tt.wantLen)
+			}
+		})
+	}
+}
+
+func Test_inferStart_openEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		plane string
+		want  string
+	}{
+		{"chain", "S-7\n..|\nL-J", "loop not closed: open end at (2,0)"},
+		{"short chain", ".S-7\n...|\n.L-J", "loop not closed: open end at (2,1)"},
+		{"out of plane", "S-\n..", "loop not closed: open end at (0,1)"},
+		{"lonely start", ".S.\n...", "loop not closed: start (0,1) has no connected pipes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plane, err := readPlane(strings.NewReader(tt.plane))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = inferStart(plane)
+			if !errors.Is(err, ErrLoopNotClosed) || err.Error() != tt.want {
+				t.Errorf("inferStart() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
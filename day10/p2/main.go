@@ -4,12 +4,15 @@ import (
 	"adventofcode-2023/lib/queue"
 	"bufio"
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
 	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -38,6 +41,14 @@ func (p Point) Valid(n, m int) bool {
 	return 0 <= p.i && p.i < n && 0 <= p.j && p.j < m
 }
 
+func (p Point) Reverse() Point {
+	return Point{-1 * p.i, -1 * p.j}
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%d,%d)", p.i, p.j)
+}
+
 var (
 	North = Point{-1, 0}
 	South = Point{1, 0}
@@ -96,58 +107,193 @@ func getToDir(c byte, fromDir Point) (Point, bool) {
 	return Point{}, false
 }
 
-type Way struct {
-	point   Point
-	fromDir Point
+var (
+	ErrStartNotFound  = errors.New("start not found")
+	ErrAmbiguousStart = errors.New("ambiguous start")
+	ErrLoopNotClosed  = errors.New("loop not closed")
+)
+
+// DanglingPipeError is returned if the pipe at the point leads nowhere
+type DanglingPipeError struct {
+	Point Point
 }
 
-func doStep(plane [][]byte, matrix [][]byte, way Way) (Way, bool) {
-	toDir, ok := getToDir(plane[way.point.i][way.point.j], way.fromDir)
-	if ok {
-		drawStep(matrix, way.point, toDir)
-		way.point = way.point.Add(toDir)
-		way.fromDir = Point{-1 * toDir.i, -1 * toDir.j}
+func (e *DanglingPipeError) Error() string {
+	return fmt.Sprintf("dangling pipe at %v", e.Point)
+}
+
+const pipes = "|-LJ7F"
+
+// getPipeDirs returns two directions connected by the pipe c
+func getPipeDirs(c byte) (Point, Point, bool) {
+	switch c {
+	case '|':
+		return North, South, true
+	case '-':
+		return East, West, true
+	case 'L':
+		return North, East, true
+	case 'J':
+		return North, West, true
+	case '7':
+		return South, West, true
+	case 'F':
+		return South, East, true
 	}
-	return way, false
+	return Point{}, Point{}, false
 }
 
-func draw(plane [][]byte) [][]byte {
+// isConnected reports whether the neighbour of p in the direction dir has a pipe
+// connected to p
+func isConnected(plane [][]byte, p, dir Point) bool {
 	n := len(plane)
 	m := len(plane[0])
 
-	start := getStart(plane)
+	neig := p.Add(dir)
+	if !neig.Valid(n, m) {
+		return false
+	}
 
-	matrix := makeMatrix(n, m)
-	drawPoint(matrix, start)
+	_, ok := getToDir(plane[neig.i][neig.j], dir.Reverse())
+	return ok
+}
+
+// followLoop follows the pipes from the start point until it comes back. It returns the
+// loop points beginning from the start one
+func followLoop(plane [][]byte, start Point) ([]Point, error) {
+	n := len(plane)
+	m := len(plane[0])
+
+	toDir, _, ok := getPipeDirs(plane[start.i][start.j])
+	if !ok {
+		return nil, &DanglingPipeError{start}
+	}
+
+	loop := []Point{start}
+
+	// each pipe connects exactly two neighbours, so the way can't come to a loop
+	// without the start point
+	for p := start; ; {
+		next := p.Add(toDir)
+		if !next.Valid(n, m) {
+			return nil, &DanglingPipeError{p}
+		}
+
+		fromDir := toDir.Reverse()
+
+		if next == start {
+			if _, ok := getToDir(plane[start.i][start.j], fromDir); !ok {
+				return nil, &DanglingPipeError{p}
+			}
+			return loop, nil
+		}
+
+		toDir, ok = getToDir(plane[next.i][next.j], fromDir)
+		if !ok {
+			return nil, &DanglingPipeError{p}
+		}
 
-	way := [2]Way{}
+		loop = append(loop, next)
+		p = next
+	}
+}
+
+// openEnd follows the pipes from the start in the direction toDir and returns the last
+// pipe of the chain, the one which leads nowhere
+func openEnd(plane [][]byte, start, toDir Point) Point {
+	n := len(plane)
+	m := len(plane[0])
+
+	for p := start; ; {
+		next := p.Add(toDir)
+		if !next.Valid(n, m) || next == start {
+			return p
+		}
+
+		dir, ok := getToDir(plane[next.i][next.j], toDir.Reverse())
+		if !ok {
+			return p
+		}
+
+		p, toDir = next, dir
+	}
+}
+
+// inferStart infers the pipe shape under S and replaces S with it. It returns the loop
+// beginning from the start point
+func inferStart(plane [][]byte) ([]Point, error) {
+	start := getStart(plane)
+	if start.i == -1 {
+		return nil, ErrStartNotFound
+	}
 
-	k := 0
-	for _, toDir := range []Point{North, South, West, East} {
-		if k == 2 {
-			break
+	var (
+		loops    [][]Point
+		shapes   []byte
+		firstErr error
+	)
+
+	for k := range pipes {
+		c := pipes[k]
+		dir1, dir2, _ := getPipeDirs(c)
+		if !isConnected(plane, start, dir1) || !isConnected(plane, start, dir2) {
+			continue
 		}
 
-		fromDir := Point{-1 * toDir.i, -1 * toDir.j}
-		if p := start.Add(toDir); p.Valid(n, m) {
-			c := plane[p.i][p.j]
-			if _, ok := getToDir(c, fromDir); ok {
-				drawStep(matrix, start, toDir)
-				way[k] = Way{fromDir: fromDir, point: p}
-				k++
+		plane[start.i][start.j] = c
+		loop, err := followLoop(plane, start)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
 			}
+			continue
 		}
+
+		loops = append(loops, loop)
+		shapes = append(shapes, c)
+	}
+
+	if debugEnable {
+		log.Printf("start %v may be %q", start, shapes)
 	}
 
-	if k != 2 { // XXX
-		panic("not found ways from start")
+	switch len(shapes) {
+	case 0:
+		plane[start.i][start.j] = 'S'
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		// less than two neighbours are connected to the start, so the pipes from it
+		// make an open chain
+		var connected []Point
+		for _, dir := range []Point{North, East, South, West} {
+			if isConnected(plane, start, dir) {
+				connected = append(connected, dir)
+			}
+		}
+		if len(connected) == 0 {
+			return nil, fmt.Errorf("%w: start %v has no connected pipes", ErrLoopNotClosed, start)
+		}
+		return nil, fmt.Errorf("%w: open end at %v", ErrLoopNotClosed, openEnd(plane, start, connected[0]))
+	case 1:
+		plane[start.i][start.j] = shapes[0]
+		return loops[0], nil
+	default:
+		plane[start.i][start.j] = 'S'
+		return nil, fmt.Errorf("%w: S at %v may be any of %q", ErrAmbiguousStart, start, shapes)
 	}
+}
+
+func draw(plane [][]byte, loop []Point) [][]byte {
+	n := len(plane)
+	m := len(plane[0])
+
+	matrix := makeMatrix(n, m)
 
-	count := 1
-	for way[0].point != way[1].point && count < n*m/2 { // XXX avoid endless loop
-		way[0], _ = doStep(plane, matrix, way[0])
-		way[1], _ = doStep(plane, matrix, way[1])
-		count++
+	for k, p := range loop {
+		next := loop[(k+1)%len(loop)]
+		drawPoint(matrix, p)
+		drawStep(matrix, p, Point{next.i - p.i, next.j - p.j})
 	}
 
 	return matrix
@@ -222,13 +368,49 @@ func calc(matrix [][]byte) int {
 	return count
 }
 
+var (
+	algorithm    = "fill"
+	renderEnable = false
+)
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	plane, err := readPlane(br)
 	if err != nil {
 		return err
 	}
 
-	matrix := draw(plane)
+	loop, err := inferStart(plane)
+	if err != nil {
+		return err
+	}
+
+	if renderEnable {
+		if err := render(bw, plane, loop); err != nil {
+			return err
+		}
+	}
+
+	var count int
+
+	switch algorithm {
+	case "fill":
+		count = countInsideFill(plane, loop)
+	case "scan":
+		count = countInsideScan(plane, loop)
+	case "shoelace":
+		count = countInsideShoelace(loop)
+	default:
+		return fmt.Errorf("unknown algorithm: %s", algorithm)
+	}
+
+	fmt.Fprintln(bw, count)
+	return nil
+}
+
+// countInsideFill draws the loop on the (2n+1)x(2m+1) matrix, so there are gaps
+// between the pipes, fills the outside from the corner and counts the rest tiles
+func countInsideFill(plane [][]byte, loop []Point) int {
+	matrix := draw(plane, loop)
 
 	if debugEnable {
 		log.Println("draw plane:")
@@ -246,9 +428,117 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 		}
 	}
 
-	count := calc(matrix)
+	return calc(matrix)
+}
+
+func markLoop(plane [][]byte, loop []Point) [][]bool {
+	n := len(plane)
+	m := len(plane[0])
+
+	buf := make([]bool, n*m)
+	onLoop := make([][]bool, n)
+	for i, j := 0, 0; i < n; i, j = i+1, j+m {
+		onLoop[i] = buf[j : j+m]
+	}
+
+	for _, p := range loop {
+		onLoop[p.i][p.j] = true
+	}
+
+	return onLoop
+}
+
+// classify returns the matrix where the loop tiles are 0, the inside tiles are 'I' and
+// the outside ones are 'O'. A tile is inside if the row to the left of it crosses the
+// loop odd times. The loop is crossed by the pipes connected to the north (|, L, J),
+// so the horizontal runs like L--7 are counted once and L--J twice
+func classify(plane [][]byte, loop []Point) [][]byte {
+	onLoop := markLoop(plane, loop)
+
+	result := make([][]byte, len(plane))
+	for i, row := range plane {
+		result[i] = make([]byte, len(row))
+		inside := false
+		for j, c := range row {
+			switch {
+			case onLoop[i][j]:
+				if c == '|' || c == 'L' || c == 'J' {
+					inside = !inside
+				}
+			case inside:
+				result[i][j] = 'I'
+			default:
+				result[i][j] = 'O'
+			}
+		}
+	}
+
+	return result
+}
+
+// countInsideScan counts the inside tiles by the crossing parity of each row
+func countInsideScan(plane [][]byte, loop []Point) int {
+	count := 0
+	for _, row := range classify(plane, loop) {
+		for _, c := range row {
+			if c == 'I' {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// countInsideShoelace counts the inside tiles by the Pick's theorem A = I + B/2 - 1,
+// where the area A of the loop polygon is calculated by the shoelace formula and B is
+// the number of the loop tiles
+func countInsideShoelace(loop []Point) int {
+	area2 := 0
+	for k, p := range loop {
+		next := loop[(k+1)%len(loop)]
+		area2 += p.j*next.i - next.j*p.i
+	}
+
+	if area2 < 0 {
+		area2 = -area2
+	}
+
+	return (area2-len(loop))/2 + 1
+}
+
+var boxChars = map[byte]rune{
+	'|': '│',
+	'-': '─',
+	'L': '└',
+	'J': '┘',
+	'7': '┐',
+	'F': '┌',
+}
+
+// render writes the maze with the loop drawn by box-drawing characters and marks the
+// other tiles as inside (I) or outside (O)
+func render(w io.Writer, plane [][]byte, loop []Point) error {
+	start := loop[0]
+
+	var sb strings.Builder
+	for i, row := range classify(plane, loop) {
+		sb.Reset()
+		for j, c := range row {
+			switch {
+			case c != 0:
+				sb.WriteByte(c)
+			case i == start.i && j == start.j:
+				sb.WriteByte('S')
+			default:
+				sb.WriteRune(boxChars[plane[i][j]])
+			}
+		}
+		sb.WriteByte('\n')
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
 
-	fmt.Fprintln(bw, count)
 	return nil
 }
 
@@ -258,7 +548,7 @@ func readPlane(r io.Reader) ([][]byte, error) {
 		return nil, err
 	}
 
-	bytes.TrimSpace(buf)
+	buf = bytes.TrimSpace(buf)
 	plane := bytes.Split(buf, []byte("\n"))
 	for i := range plane {
 		plane[i] = bytes.TrimSpace(plane[i])
@@ -281,6 +571,11 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&algorithm, "algo", algorithm, "enclosed tiles counting algorithm: fill|scan|shoelace")
+	flag.BoolVar(&renderEnable, "render", renderEnable, "render the maze with the inside (I) and outside (O) tiles")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"io"
+	"os"
 	"strings"
 	"testing"
 )
@@ -65,6 +66,15 @@ L7JLJL-JLJLJL--JLJ.L`)},
 			false,
 			true,
 		},
+		{
+			"dangling",
+			args{strings.NewReader(`S-7
+			|.|
+			L-.`)},
+			``,
+			true,
+			true,
+		},
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
@@ -82,3 +92,77 @@ L7JLJL-JLJLJL--JLJ.L`)},
 		})
 	}
 }
+
+func Test_run_algorithms(t *testing.T) {
+	input, err := os.ReadFile("../adventofcode.com_2023_day_10_input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"squeeze",
+			`..........
+			.S------7.
+			.|F----7|.
+			.||....||.
+			.||....||.
+			.|L-7F-J|.
+			.|..||..|.
+			.L--JL--J.
+			..........`,
+			`4`,
+		},
+		{
+			"input",
+			string(input),
+			`443`,
+		},
+	}
+	for _, tt := range tests {
+		for _, algo := range []string{"fill", "scan", "shoelace"} {
+			t.Run(tt.name+" "+algo, func(t *testing.T) {
+				algorithm = algo
+				defer func() { algorithm = "fill" }()
+				w := &bytes.Buffer{}
+				if err := run(strings.NewReader(tt.input), w); err != nil {
+					t.Fatalf("run() error = %v", err)
+				}
+				if gotW := w.String(); strings.TrimSpace(gotW) != tt.want {
+					t.Errorf("run() = %v, want %v", gotW, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func Test_render(t *testing.T) {
+	renderEnable = true
+	algorithm = "scan"
+	defer func() { renderEnable = false; algorithm = "fill" }()
+
+	w := &bytes.Buffer{}
+	err := run(strings.NewReader(`.....
+	.S-7.
+	.|.|.
+	.L-J.
+	..F..`), w)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	want := `OOOOO
+OS─┐O
+O│I│O
+O└─┘O
+OOOOO
+1
+`
+	if got := w.String(); got != want {
+		t.Errorf("run() = \n%v, want \n%v", got, want)
+	}
+}
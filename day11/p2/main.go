@@ -2,36 +2,89 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"math/big"
 	"os"
+	"sort"
 	"strconv"
 	"unsafe"
 )
 
-var multiplier = int(1e6)
+var (
+	multiplier   = int(1e6) // expansion factor of the empty rows and columns
+	printMatrix  = false
+	nearestQuery = 0 // galaxy number (from 1) to find the nearest galaxy for
+)
 
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	if multiplier < 1 {
+		return fmt.Errorf("expansion factor must be positive, got %d", multiplier)
+	}
+
+	points, n, m, err := readImage(br)
+	if err != nil {
+		return err
+	}
+
+	// the number of empty rows (columns) before each row (column)
+
+	emptyRows := countEmptyBefore(points, 0, n)
+	emptyCols := countEmptyBefore(points, 1, m)
 
-	// читаем карту (координаты объектов и размеры карты)
+	if printMatrix {
+		writeMatrix(bw, points, emptyRows, emptyCols)
+	}
+
+	if nearestQuery != 0 {
+		if err := writeNearest(bw, points, emptyRows, emptyCols, nearestQuery); err != nil {
+			return err
+		}
+	}
+
+	// the sum of the Manhattan distances splits by axes, and each distance splits into
+	// the original distance and the number of the empty lines between
 
-	var n, m int
-	var points [][2]int
+	var origSum, emptySum int
 
+	coords := make([]int, len(points))
+	for axis, empty := range [2][]int{emptyRows, emptyCols} {
+		for k, p := range points {
+			coords[k] = p[axis]
+		}
+		sort.Ints(coords)
+
+		origSum += pairwiseSum(coords, func(x int) int { return x })
+		emptySum += pairwiseSum(coords, func(x int) int { return empty[x] })
+	}
+
+	if debugEnable {
+		log.Printf("orig: %d empty: %d", origSum, emptySum)
+	}
+
+	fmt.Fprintln(bw, expandDist(origSum, emptySum))
+	return nil
+}
+
+func readImage(br *bufio.Reader) (points [][2]int, n, m int, err error) {
 	for {
 		line, isPrefix, err := br.ReadLine()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return fmt.Errorf("%d: %w", n+1, err)
+			return nil, 0, 0, fmt.Errorf("%d: %w", n+1, err)
 		}
 		if isPrefix { // XXX
-			return fmt.Errorf("%d: line too long", n+1)
+			return nil, 0, 0, fmt.Errorf("%d: line too long", n+1)
 		}
 
+		line = bytes.TrimSpace(line)
+
 		for j, c := range line {
 			if c == '#' {
 				points = append(points, [2]int{n, j})
@@ -42,55 +95,102 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 		m = max(m, len(line))
 	}
 
-	// ищем расширившиеся строки и столбцы (в которых нет объектов)
+	return points, n, m, nil
+}
 
-	rows := make([]int, m+1)
-	cols := make([]int, n+1)
+// countEmptyBefore returns for each line of the axis the number of empty lines (without
+// galaxies) before it
+func countEmptyBefore(points [][2]int, axis, size int) []int {
+	used := make([]bool, size)
+	for _, p := range points {
+		used[p[axis]] = true
+	}
 
-	for i := 1; i < len(rows); i++ {
-		rows[i] = multiplier-1
+	empty := make([]int, size)
+	for i := 1; i < size; i++ {
+		empty[i] = empty[i-1]
+		if !used[i-1] {
+			empty[i]++
+		}
 	}
 
-	for i := 1; i < len(cols); i++ {
-		cols[i] = multiplier-1
+	return empty
+}
+
+// pairwiseSum returns the sum of |f(a)-f(b)| for all pairs of the sorted coordinates.
+// f must be non-decreasing
+func pairwiseSum(coords []int, f func(x int) int) int {
+	total := 0
+	prefix := 0
+	for k, x := range coords {
+		v := f(x)
+		total += v*k - prefix
+		prefix += v
 	}
+	return total
+}
 
-	for _, p := range points {
-		rows[p[0]] = 0
-		cols[p[1]] = 0
+// expandDist returns orig + (multiplier-1)*empty. It calculates with *big.Int only if
+// the result overflows int
+func expandDist(orig, empty int) *big.Int {
+	k := multiplier - 1
+	if empty == 0 || k <= (math.MaxInt-orig)/empty {
+		return big.NewInt(int64(orig + k*empty))
 	}
 
-	// вычисляем увеличение для каждой строки и столбца (префикные суммы)
+	v := new(big.Int).Mul(big.NewInt(int64(k)), big.NewInt(int64(empty)))
+	return v.Add(v, big.NewInt(int64(orig)))
+}
 
-	for i := 1; i < len(rows); i++ {
-		rows[i] += rows[i-1]
-	}
+func dist(p1, p2 [2]int, emptyRows, emptyCols []int) *big.Int {
+	orig := abs(p2[0]-p1[0]) + abs(p2[1]-p1[1])
+	empty := abs(emptyRows[p2[0]]-emptyRows[p1[0]]) + abs(emptyCols[p2[1]]-emptyCols[p1[1]])
+	return expandDist(orig, empty)
+}
 
-	for i := 1; i < len(cols); i++ {
-		cols[i] += cols[i-1]
+// writeMatrix writes the pairwise distances between galaxies
+func writeMatrix(w io.Writer, points [][2]int, emptyRows, emptyCols []int) {
+	for _, p1 := range points {
+		for k, p2 := range points {
+			if k > 0 {
+				fmt.Fprint(w, " ")
+			}
+			fmt.Fprint(w, dist(p1, p2, emptyRows, emptyCols))
+		}
+		fmt.Fprintln(w)
 	}
+}
 
-	// корректируем (увеличиваем) координаты объектов
+// writeNearest writes the galaxy nearest to the galaxy with the number (from 1)
+func writeNearest(w io.Writer, points [][2]int, emptyRows, emptyCols []int, number int) error {
+	if number < 1 || number > len(points) {
+		return fmt.Errorf("galaxy %d not found, there are %d galaxies", number, len(points))
+	}
 
-	for i := range points {
-		p := &points[i]
-		p[0] += rows[p[0]]
-		p[1] += cols[p[1]]
+	if len(points) < 2 {
+		return fmt.Errorf("there is only one galaxy")
 	}
 
-	// считаем сумму манхэттенских расстояний между объектами
+	p1 := points[number-1]
 
-	total := 0
-	for i, p1 := range points {
-		for _, p2 := range points[i+1:] {
-			dist := abs(p2[0]-p1[0]) + abs(p2[1]-p1[1])
-			total += dist
-		} 
-	}
+	var (
+		nearest int
+		minDist *big.Int
+	)
 
-	// bingo!
+	for k, p2 := range points {
+		if k == number-1 {
+			continue
+		}
 
-	fmt.Fprintln(bw, total)
+		d := dist(p1, p2, emptyRows, emptyCols)
+		if minDist == nil || d.Cmp(minDist) < 0 {
+			nearest = k + 1
+			minDist = d
+		}
+	}
+
+	fmt.Fprintf(w, "galaxy %d: nearest %d, distance %v\n", number, nearest, minDist)
 	return nil
 }
 
@@ -122,6 +222,12 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.IntVar(&multiplier, "expand", multiplier, "expansion factor of the empty rows and columns")
+	flag.BoolVar(&printMatrix, "matrix", printMatrix, "print the pairwise distance matrix")
+	flag.IntVar(&nearestQuery, "nearest", nearestQuery, "print the galaxy nearest to the galaxy `number` (from 1)")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
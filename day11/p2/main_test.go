@@ -92,3 +92,85 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_debugModes(t *testing.T) {
+	const example = `...#......
+.......#..
+#.........
+..........
+......#...
+.#........
+.........#
+..........
+.......#..
+#...#.....`
+
+	tests := []struct {
+		name       string
+		multiplier int
+		matrix     bool
+		nearest    int
+		input      string
+		wantW      string
+		wantErr    bool
+	}{
+		{
+			"overflow",
+			1e18,
+			false,
+			0,
+			example,
+			`82000000000000000210`,
+			false,
+		},
+		{
+			"matrix",
+			2,
+			true,
+			0,
+			`#..
+			...
+			..#`,
+			`0 6
+6 0
+6`,
+			false,
+		},
+		{
+			"nearest",
+			2,
+			false,
+			5,
+			example,
+			`galaxy 5: nearest 3, distance 5
+374`,
+			false,
+		},
+		{
+			"nearest not found",
+			2,
+			false,
+			10,
+			example,
+			``,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiplier = tt.multiplier
+			printMatrix = tt.matrix
+			nearestQuery = tt.nearest
+			defer func() { printMatrix = false; nearestQuery = 0 }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -2,7 +2,8 @@ package main
 
 import (
 	"bufio"
-	"errors"
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -13,10 +14,19 @@ import (
 	"unsafe"
 )
 
+var (
+	smudgeCount = 1
+	printAxes   = false
+)
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	if smudgeCount < 0 {
+		return fmt.Errorf("smudge count must be non-negative, got %d", smudgeCount)
+	}
+
 	sum := 0
 
-	for {
+	for number := 1; ; number++ {
 		rows, cols, err := readMirror(br)
 		if rows == nil {
 			if err != nil && err != io.EOF {
@@ -30,76 +40,149 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 			log.Println("cols:", cols)
 		}
 
-		if n, ok := searchAxis(cols); ok {
+		colAxes := searchAxes(cols, smudgeCount)
+		rowAxes := searchAxes(rows, smudgeCount)
+
+		for _, n := range colAxes {
 			sum += n
-			continue
 		}
 
-		if n, ok := searchAxis(rows); ok {
+		for _, n := range rowAxes {
 			sum += 100 * n
 		}
+
+		if printAxes {
+			writeAxes(bw, number, colAxes, rowAxes)
+		}
 	}
 
 	fmt.Fprintln(bw, sum)
 	return nil
 }
 
-func searchAxis(nums []uint) (int, bool) {
-	for n := 1; n < len(nums); n++ {
-		bingo := 0
+func writeAxes(w io.Writer, number int, colAxes, rowAxes []int) {
+	fmt.Fprintf(w, "pattern %d:", number)
+	for _, n := range colAxes {
+		fmt.Fprintf(w, " column %d", n)
+	}
+	for _, n := range rowAxes {
+		fmt.Fprintf(w, " row %d", n)
+	}
+
+	switch len(colAxes) + len(rowAxes) {
+	case 0:
+		fmt.Fprint(w, " no reflection")
+	case 1:
+		// ok
+	default:
+		fmt.Fprint(w, " (ambiguous)")
+	}
+
+	fmt.Fprintln(w)
+}
+
+// Line is a row or a column of the pattern as a bit set of the rocks
+type Line []uint64
+
+func makeLine(size int) Line {
+	return make(Line, (size+63)/64)
+}
+
+func (l Line) Set(k int) {
+	l[k/64] |= 1 << (k % 64)
+}
+
+// Diff returns the number of differing positions
+func (l Line) Diff(other Line) int {
+	count := 0
+	for k := range l {
+		count += bits.OnesCount64(l[k] ^ other[k])
+	}
+	return count
+}
+
+// searchAxes returns all axes n (the number of lines before the axis) such that the
+// reflection differs in exactly smudges positions
+func searchAxes(lines []Line, smudges int) []int {
+	var axes []int
 
-		for i, j := n-1, n; i >= 0 && j < len(nums); i, j = i-1, j+1 {
-			bingo += bits.OnesCount(nums[i] ^ nums[j])
-			if bingo > 1 {
+	for n := 1; n < len(lines); n++ {
+		diff := 0
+
+		for i, j := n-1, n; i >= 0 && j < len(lines); i, j = i-1, j+1 {
+			diff += lines[i].Diff(lines[j])
+			if diff > smudges {
 				break
 			}
 		}
 
-		if bingo == 1 {
-			return n, true
+		if diff == smudges {
+			axes = append(axes, n)
 		}
 	}
 
-	return 0, false
+	return axes
 }
 
-func readMirror(br *bufio.Reader) (rows, cols []uint, err error) {
-	for i := 0; ; i++ {
-		line, isPrefix, err := br.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				return rows, cols, err
-			}
+func readMirror(br *bufio.Reader) (rows, cols []Line, err error) {
+	var (
+		lines [][]byte
+		width int
+	)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
 			return nil, nil, err
 		}
-		if isPrefix {
-			return nil, nil, errors.New("line too long")
-		}
+
+		eof := err == io.EOF
+		line = bytes.TrimSpace(line)
+
 		if len(line) == 0 {
-			return rows, cols, nil
+			if len(lines) == 0 && !eof {
+				continue // extra empty line between patterns
+			}
+			break
 		}
 
-		if cols == nil {
-			if len(line) > 64 {
-				return nil, nil, errors.New("line is longer than 64 characters")
-			}
-			cols = make([]uint, len(line))
+		if width == 0 {
+			width = len(line)
+		} else if len(line) != width {
+			return nil, nil, fmt.Errorf("line %q: width %d expected", line, width)
+		}
+
+		lines = append(lines, line)
 
+		if eof {
+			break
 		}
+	}
 
-		rows = append(rows, calcLine(line, i, cols))
+	if len(lines) == 0 {
+		return nil, nil, io.EOF
 	}
-}
 
-func calcLine(line []byte, i int, cols []uint) uint {
-	var row uint
-	for j, c := range line {
-		if c == '#' {
-			cols[j] |= 1 << i
-			row |= 1 << j
+	rows = make([]Line, len(lines))
+	for i := range rows {
+		rows[i] = makeLine(width)
+	}
+
+	cols = make([]Line, width)
+	for j := range cols {
+		cols[j] = makeLine(len(lines))
+	}
+
+	for i, line := range lines {
+		for j, c := range line {
+			if c == '#' {
+				rows[i].Set(j)
+				cols[j].Set(i)
+			}
 		}
 	}
-	return row
+
+	return rows, cols, nil
 }
 
 func run(r io.Reader, w io.Writer) (err error) {
@@ -116,6 +199,11 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.IntVar(&smudgeCount, "smudges", smudgeCount, "number of smudges on each mirror")
+	flag.BoolVar(&printAxes, "axes", printAxes, "print all reflection axes of each pattern")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
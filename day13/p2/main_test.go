@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"io"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -70,3 +71,106 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_smudges(t *testing.T) {
+	const example = `#.##..##.
+..#.##.#.
+##......#
+##......#
+..#.##.#.
+..##..##.
+#.#.##.#.
+
+#...##..#
+#....#..#
+..##..###
+#####.##.
+#####.##.
+..##..###
+#....#..#`
+
+	rnd := rand.New(rand.NewSource(14))
+	randomLine := func(size int) string {
+		b := make([]byte, size)
+		for k := range b {
+			b[k] = ".#"[rnd.Intn(2)]
+		}
+		return string(b)
+	}
+	mirrored := func(half string) string {
+		return half + reverseString(half)
+	}
+
+	wide := mirrored(randomLine(50)) + "\n" + mirrored(randomLine(50))
+
+	var tall strings.Builder
+	top := []string{randomLine(60), randomLine(60)}
+	col0, col1 := mirrored(top[0]), mirrored(top[1])
+	for i := range col0 {
+		tall.WriteByte(col0[i])
+		tall.WriteByte(col1[i])
+		tall.WriteByte('\n')
+	}
+
+	tests := []struct {
+		name    string
+		smudges int
+		axes    bool
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{"0", 0, false, example, `405`, false},
+		{"1", 1, false, example, `400`, false},
+		{"axes", 0, true, example, "pattern 1: column 5\npattern 2: row 4\n405", false},
+		{
+			"wide",
+			0,
+			false,
+			wide,
+			`50`,
+			false,
+		},
+		{
+			"tall",
+			0,
+			true,
+			tall.String(),
+			"pattern 1: row 60\n6000",
+			false,
+		},
+		{
+			"ambiguous",
+			0,
+			true,
+			"....\n....",
+			"pattern 1: column 1 column 2 column 3 row 1 (ambiguous)\n106",
+			false,
+		},
+		{"bad width", 0, false, "#..\n#.", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smudgeCount = tt.smudges
+			printAxes = tt.axes
+			defer func() { smudgeCount = 1; printAxes = false }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
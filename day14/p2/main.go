@@ -3,56 +3,148 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"strconv"
 )
 
-const maxCount = 1000
+var (
+	tiltSequence = "NWSE"
+	cycleCount   = Count(1_000_000_000)
+	printPlane   = false
+	printCycles  = 0 // if set, print the platform after each of the first cycles instead
+)
+
+// Dir is the direction to which the platform is tilted
+type Dir byte
+
+const (
+	North Dir = 'N'
+	West  Dir = 'W'
+	South Dir = 'S'
+	East  Dir = 'E'
+)
+
+// parseSequence parses the tilt sequence like "NWSE"
+func parseSequence(s string) ([]Dir, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty tilt sequence")
+	}
+
+	seq := make([]Dir, 0, len(s))
+	for _, c := range s {
+		switch dir := Dir(c); dir {
+		case North, West, South, East:
+			seq = append(seq, dir)
+		default:
+			return nil, fmt.Errorf("unknown tilt direction '%c', one of NWSE expected", c)
+		}
+	}
+
+	return seq, nil
+}
+
+// Count is a flag.Value for non-negative integer that also accepts e-notation (1e9)
+type Count int
+
+func (c Count) String() string {
+	return strconv.Itoa(int(c))
+}
+
+func (c *Count) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		f, err2 := strconv.ParseFloat(s, 64)
+		if err2 != nil || f != math.Trunc(f) || f > math.MaxInt {
+			return err
+		}
+		v = int(f)
+	}
+
+	if v < 0 {
+		return fmt.Errorf("count must be non-negative, got %d", v)
+	}
+
+	*c = Count(v)
+	return nil
+}
 
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	text, plane, err := readPlane(br)
+	_, plane, err := readPlane(br)
+	if err != nil {
+		return err
+	}
+
+	seq, err := parseSequence(tiltSequence)
 	if err != nil {
 		return err
 	}
 
-	var values []int
-	hashes := make(map[uint64]int)
+	if printCycles > 0 {
+		return writeCycles(bw, plane, seq, printCycles)
+	}
+
+	plane = spinPlane(plane, seq, int(cycleCount))
+
+	fmt.Fprintln(bw, calcPlane(plane))
+
+	if printPlane {
+		for _, row := range plane {
+			fmt.Fprintf(bw, "%s\n", row)
+		}
+	}
 
-	var (
-		periodBegin int
-		periodSize  int
-	)
+	return nil
+}
+
+// spinPlane returns the platform after count cycles of the tilt sequence. It detects
+// the period by the exact comparison of the platform states
+func spinPlane(plane [][]byte, seq []Dir, count int) [][]byte {
+	m := len(plane[0])
 
-	for i := 0; i < maxCount; i++ {
-		toNorth(plane)
-		toWest(plane)
-		toSouth(plane)
-		toEast(plane)
+	var states []string
+	seen := make(map[string]int)
 
-		h := djb2Hash(text)
+	state := string(bytes.Join(plane, nil))
+	states = append(states, state)
+	seen[state] = 0
 
-		if idx, ok := hashes[h]; ok {
-			periodBegin = idx
-			periodSize = i - idx
+	for i := 1; i <= count; i++ {
+		spin(plane, seq)
+
+		state = string(bytes.Join(plane, nil))
+		if idx, ok := seen[state]; ok {
+			periodBegin := idx
+			periodSize := i - idx
+
+			if debugEnable {
+				log.Printf("period: begin=%d size=%d", periodBegin, periodSize)
+			}
+
+			state = states[(count-periodBegin)%periodSize+periodBegin]
 			break
 		}
 
-		v := calcPlane(plane)
+		states = append(states, state)
+		seen[state] = i
+	}
 
-		values = append(values, v)
-		hashes[h] = i
+	buf := []byte(state)
+	for i := range plane {
+		plane[i] = buf[i*m : (i+1)*m]
 	}
 
-	idx := (1_000_000_000 - periodBegin - 1) % periodSize + periodBegin
+	return plane
+}
 
-	if debugEnable {
-		log.Printf("%d %d %v", periodBegin, periodSize, values)
+func spin(plane [][]byte, seq []Dir) {
+	for _, dir := range seq {
+		tilt(plane, dir)
 	}
-
-	fmt.Fprintln(bw, values[idx])
-	return nil
 }
 
 func calcPlane(plane [][]byte) int {
@@ -70,14 +162,6 @@ func calcPlane(plane [][]byte) int {
 	return total
 }
 
-func djb2Hash(text []byte) uint64 {
-	hash := uint64(5381)
-	for _, c := range text {
-		hash = (hash << 5) + hash + uint64(c) // (hash * 33) + c
-	}
-	return hash
-}
-
 func debugPlane(title string, plane [][]byte) {
 	if debugEnable {
 		log.Printf("%s:", title)
@@ -87,14 +171,10 @@ func debugPlane(title string, plane [][]byte) {
 	}
 }
 
-func testOfCicles(r io.Reader, w io.Writer, n int) {
-	_, plane, _ := readPlane(r)
-
+// writeCycles writes the platform after each of the first n cycles
+func writeCycles(w io.Writer, plane [][]byte, seq []Dir, n int) error {
 	for i := 0; i < n; i++ {
-		toNorth(plane)
-		toWest(plane)
-		toSouth(plane)
-		toEast(plane)
+		spin(plane, seq)
 
 		if i == 0 {
 			fmt.Fprintf(w, "After 1 cycle:\n")
@@ -103,125 +183,54 @@ func testOfCicles(r io.Reader, w io.Writer, n int) {
 		}
 
 		for _, row := range plane {
-			fmt.Fprintf(w, "%s\n", row)
-		}
-	}
-}
-
-func toNorth(plane [][]byte) int {
-	n := len(plane)
-	m := len(plane[0])
-
-	vals := make([]int, m)
-	for j := range vals {
-		vals[j] = n
-	}
-
-	total := 0
-
-	for i := range plane {
-		for j := 0; j < m; j++ {
-			switch plane[i][j] {
-			case 'O':
-				v := vals[j]
-				ii := n - v
-				plane[i][j], plane[ii][j] = plane[ii][j], plane[i][j]
-				total += v
-				vals[j]--
-			case '#':
-				vals[j] = n - i - 1
-			}
-		}
-	}
-
-	return total
-}
-
-func toWest(plane [][]byte) int {
-	n := len(plane)
-	m := len(plane[0])
-
-	vals := make([]int, n)
-	for i := range vals {
-		vals[i] = m
-	}
-
-	total := 0
-
-	for i := range plane {
-		for j := 0; j < m; j++ {
-			switch plane[i][j] {
-			case 'O':
-				v := vals[i]
-				jj := m - v
-				plane[i][j], plane[i][jj] = plane[i][jj], plane[i][j]
-				total += v
-				vals[i]--
-			case '#':
-				vals[i] = m - j - 1
+			if _, err := fmt.Fprintf(w, "%s\n", row); err != nil {
+				return err
 			}
 		}
 	}
 
-	return total
+	return nil
 }
 
-func toSouth(plane [][]byte) int {
+// tilt rolls all rounded rocks to the dir edge of the platform
+func tilt(plane [][]byte, dir Dir) {
 	n := len(plane)
 	m := len(plane[0])
 
-	vals := make([]int, m)
-	for j := range vals {
-		vals[j] = n
+	// the lines are perpendicular to the edge, k = 0 is the cell at the edge
+	lines, size := m, n
+	if dir == West || dir == East {
+		lines, size = n, m
 	}
 
-	total := 0
-
-	for i := n - 1; i >= 0; i-- {
-		for j := 0; j < m; j++ {
-			switch plane[i][j] {
-			case 'O':
-				v := vals[j]
-				ii := v - 1
-				plane[i][j], plane[ii][j] = plane[ii][j], plane[i][j]
-				total += v
-				vals[j]--
-			case '#':
-				vals[j] = i
-			}
+	cell := func(line, k int) *byte {
+		switch dir {
+		case North:
+			return &plane[k][line]
+		case South:
+			return &plane[n-1-k][line]
+		case West:
+			return &plane[line][k]
+		default: // East
+			return &plane[line][m-1-k]
 		}
 	}
 
-	return total
-}
-
-func toEast(plane [][]byte) int {
-	n := len(plane)
-	m := len(plane[0])
-
-	vals := make([]int, n)
-	for i := range vals {
-		vals[i] = m
-	}
-
-	total := 0
-
-	for i := range plane {
-		for j := m - 1; j >= 0; j-- {
-			switch plane[i][j] {
+	for line := 0; line < lines; line++ {
+		free := 0
+		for k := 0; k < size; k++ {
+			switch c := cell(line, k); *c {
 			case 'O':
-				v := vals[i]
-				jj := v - 1
-				plane[i][j], plane[i][jj] = plane[i][jj], plane[i][j]
-				total += v
-				vals[i]--
+				if k != free {
+					*cell(line, free) = 'O'
+					*c = '.'
+				}
+				free++
 			case '#':
-				vals[i] = j
+				free = k + 1
 			}
 		}
 	}
-
-	return total
 }
 
 func reverse[T any](a []T) {
@@ -285,6 +294,13 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&tiltSequence, "seq", tiltSequence, "tilt sequence of one cycle (N, W, S, E)")
+	flag.Var(&cycleCount, "cycles", "number of cycles (e-notation is allowed)")
+	flag.BoolVar(&printPlane, "print", printPlane, "print the platform after the cycles")
+	flag.IntVar(&printCycles, "print-cycles", printCycles, "print the platform after each of the first `n` cycles")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
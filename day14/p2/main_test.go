@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -66,7 +67,7 @@ O.#..O.#.#
 	}
 }
 
-func Test_testOfCicles(t *testing.T) {
+func Test_writeCycles(t *testing.T) {
 	type args struct {
 		r io.Reader
 		n int
@@ -88,8 +89,7 @@ O.#..O.#.#
 .......O..
 #....###..
 #OO..#....`),
-			3,
-
+				3,
 			},
 			`After 1 cycle:
 .....#....
@@ -131,10 +131,133 @@ After 3 cycles:
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			_, plane, err := readPlane(tt.args.r)
+			if err != nil {
+				t.Fatal(err)
+			}
 			w := &bytes.Buffer{}
-			testOfCicles(tt.args.r, w, tt.args.n)
+			if err := writeCycles(w, plane, []Dir{North, West, South, East}, tt.args.n); err != nil {
+				t.Fatal(err)
+			}
 			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
-				t.Errorf("testOfCicles() = %v, want %v", gotW, tt.wantW)
+				t.Errorf("writeCycles() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func Test_tilt(t *testing.T) {
+	plane := func(rows ...string) [][]byte {
+		p := make([][]byte, len(rows))
+		for i, row := range rows {
+			p[i] = []byte(row)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name  string
+		plane [][]byte
+		dir   Dir
+		want  [][]byte
+	}{
+		{"north", plane(".O#", "O.O", "OO."), North, plane("OO#", "OOO", "...")},
+		{"south", plane(".O#", "O.O", "OO."), South, plane("..#", "OO.", "OOO")},
+		{"west", plane(".O.#.O", "..O.O."), West, plane("O..#O.", "OO....")},
+		{"east", plane(".O.#.O", "..O.O."), East, plane("..O#.O", "....OO")},
+		{"north tall", plane("..", "O#", ".O", "OO"), North, plane("O.", "O#", ".O", ".O")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tilt(tt.plane, tt.dir)
+			if !reflect.DeepEqual(tt.plane, tt.want) {
+				t.Errorf("tilt() = %q, want %q", tt.plane, tt.want)
+			}
+		})
+	}
+}
+
+func Test_run_flags(t *testing.T) {
+	const example = `O....#....
+O.OO#....#
+.....##...
+OO.#O....O
+.O.....O#.
+O.#..O.#.#
+..O..#O..O
+.......O..
+#....###..
+#OO..#....`
+
+	tests := []struct {
+		name    string
+		seq     string
+		cycles  Count
+		print   bool
+		wantW   string
+		wantErr bool
+	}{
+		{"NWSE 1e9", "NWSE", 1_000_000_000, false, `64`, false},
+		{"N", "N", 1, false, `136`, false},
+		{"NWSE 0", "NWSE", 0, false, `104`, false},
+		{"NWSE 3", "NWSE", 3, true, `69
+.....#....
+....#...O#
+.....##...
+..O#......
+.....OOO#.
+.O#...O#.#
+....O#...O
+.......OOO
+#...O###.O
+#.OOO#...O`, false},
+		{"bad seq", "NX", 1, false, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiltSequence = tt.seq
+			cycleCount = tt.cycles
+			printPlane = tt.print
+			defer func() {
+				tiltSequence = "NWSE"
+				cycleCount = 1_000_000_000
+				printPlane = false
+			}()
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(example), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func TestCount_Set(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Count
+		wantErr bool
+	}{
+		{"1000", 1000, false},
+		{"1e9", 1_000_000_000, false},
+		{"2.5e1", 25, false},
+		{"2.5", 0, true},
+		{"-1", 0, true},
+		{"x", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var c Count
+			err := c.Set(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Count.Set() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if c != tt.want {
+				t.Errorf("Count.Set() = %v, want %v", c, tt.want)
 			}
 		})
 	}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"adventofcode-2023/lib/hashmap"
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -20,31 +22,87 @@ func hash(text string) int {
 	return hash
 }
 
-type Item struct {
+// Step is a single step of the initialization sequence. Op is '=' or '-'
+type Step struct {
 	lab string
+	op  byte
 	val int
 }
 
-func parseItem(b []byte) (string, int, error) {
+func (s Step) String() string {
+	if s.op == '-' {
+		return s.lab + "-"
+	}
+	return s.lab + "=" + strconv.Itoa(s.val)
+}
+
+func parseStep(b []byte) (Step, error) {
+	if len(b) == 0 {
+		return Step{}, fmt.Errorf("empty step")
+	}
+
 	if b[len(b)-1] == '-' {
-		lab := string(b[:len(b)-1])
-		return lab, -1, nil
+		return Step{lab: string(b[:len(b)-1]), op: '-'}, nil
 	}
 
 	labVal := bytes.Split(b, []byte("="))
 	if len(labVal) != 2 {
-		return "", 0, fmt.Errorf("invalid item: %s", b)
+		return Step{}, fmt.Errorf("invalid step: %s", b)
 	}
 
-	lab := string(labVal[0])
 	val, err := strconv.Atoi(string(labVal[1]))
 	if err != nil {
-		return "", 0, err
+		return Step{}, err
 	}
 
-	return lab, val, nil
+	return Step{lab: string(labVal[0]), op: '=', val: val}, nil
 }
 
+// Lenses is the HASHMAP of lens labels to focal lengths
+type Lenses = hashmap.HashMap[string, int]
+
+func NewLenses() *Lenses {
+	return hashmap.New[string, int](256, hash)
+}
+
+// apply applies the step to the boxes
+func apply(lenses *Lenses, s Step) {
+	switch s.op {
+	case '=':
+		lenses.Set(s.lab, s.val)
+	case '-':
+		lenses.Delete(s.lab)
+	}
+}
+
+// focusingPower returns the sum of box number * slot number * focal length of each lens
+func focusingPower(lenses *Lenses) int {
+	sum := 0
+	lenses.Range(func(box, slot int, _ string, val int) bool {
+		sum += (box + 1) * (slot + 1) * val
+		return true
+	})
+	return sum
+}
+
+// writeBoxes writes the non-empty boxes as in the puzzle description
+func writeBoxes(w io.Writer, lenses *Lenses) {
+	for box := 0; box < lenses.BoxCount(); box++ {
+		entries := lenses.Box(box)
+		if len(entries) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "Box %d:", box)
+		for _, e := range entries {
+			fmt.Fprintf(w, " [%s %d]", e.Key, e.Value)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+var traceEnable = false
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	buf, err := io.ReadAll(br)
 	if err != nil {
@@ -54,49 +112,28 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	buf = bytes.TrimSpace(buf)
 	words := bytes.Split(buf, []byte(","))
 
-	items := make([]Item, 0, len(words))
-	index := make(map[string]int, len(words))
+	lenses := NewLenses()
 
-	for _, it := range words {
-		lab, val, err := parseItem(it)
+	for _, word := range words {
+		s, err := parseStep(word)
 		if err != nil {
 			return err
 		}
 
 		if debugEnable {
-			log.Println(lab, val)
-		}
-
-		if idx, ok := index[lab]; ok {
-			items[idx].val = val
-			if val == -1 {
-				delete(index, lab)
-			}
-		} else if val != -1 {
-			n := len(items)
-			items = append(items, Item{lab: lab, val: val})
-			index[lab] = n
+			log.Println(s)
 		}
 
-	}
-
-	if debugEnable {
-		log.Println("items:", items)
-	}
-
-	boxs := make([]int, 256)
-	sum := 0
+		apply(lenses, s)
 
-	for _, it := range items {
-		if it.val == -1 {
-			continue
+		if traceEnable {
+			fmt.Fprintf(bw, "After %q:\n", s)
+			writeBoxes(bw, lenses)
+			fmt.Fprintln(bw)
 		}
-		h := hash(it.lab)
-		boxs[h]++
-		sum += (h + 1) * boxs[h] * it.val
 	}
 
-	fmt.Fprintln(bw, sum)
+	fmt.Fprintln(bw, focusingPower(lenses))
 	return nil
 }
 
@@ -114,6 +151,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.BoolVar(&traceEnable, "trace", traceEnable, "print the boxes after each step")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -83,3 +84,71 @@ func Test_hash(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_trace(t *testing.T) {
+	traceEnable = true
+	defer func() { traceEnable = false }()
+
+	w := &bytes.Buffer{}
+	if err := run(strings.NewReader(`rn=1,cm-,qp=3,cm=2,qp-,pc=4`), w); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	want := `After "rn=1":
+Box 0: [rn 1]
+
+After "cm-":
+Box 0: [rn 1]
+
+After "qp=3":
+Box 0: [rn 1]
+Box 1: [qp 3]
+
+After "cm=2":
+Box 0: [rn 1] [cm 2]
+Box 1: [qp 3]
+
+After "qp-":
+Box 0: [rn 1] [cm 2]
+
+After "pc=4":
+Box 0: [rn 1] [cm 2]
+Box 3: [pc 4]
+
+21
+`
+	if got := w.String(); got != want {
+		t.Errorf("run() = \n%v, want \n%v", got, want)
+	}
+}
+
+func Test_Lenses(t *testing.T) {
+	lenses := NewLenses()
+	for _, s := range []Step{
+		{"rn", '=', 1}, {"cm", '=', 2}, {"ot", '=', 9}, {"ab", '=', 5},
+		{"rn", '-', 0}, {"ot", '=', 7}, {"xx", '-', 0},
+	} {
+		apply(lenses, s)
+	}
+
+	if got := lenses.Len(); got != 3 {
+		t.Errorf("Len() = %v, want 3", got)
+	}
+	if val, ok := lenses.Get("ot"); !ok || val != 7 {
+		t.Errorf("Get(ot) = %v, %v, want 7, true", val, ok)
+	}
+	if _, ok := lenses.Get("rn"); ok {
+		t.Errorf("Get(rn) found removed lens")
+	}
+
+	// ot and ab both go to the box 3, ot keeps its slot after the replace
+	var got []string
+	lenses.Range(func(box, slot int, key string, val int) bool {
+		got = append(got, fmt.Sprintf("%d:%d:%s=%d", box, slot, key, val))
+		return true
+	})
+	want := "0:0:cm=2 3:0:ot=7 3:1:ab=5"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Range() = %v, want %v", got, want)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"adventofcode-2023/lib/queue"
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/bits"
 	"os"
 )
 
@@ -18,111 +21,234 @@ const (
 	BottomToTop
 )
 
+func (d Dir) String() string {
+	switch d {
+	case LeftToRight:
+		return ">"
+	case RigthToLeft:
+		return "<"
+	case TopToBottom:
+		return "v"
+	case BottomToTop:
+		return "^"
+	default:
+		return fmt.Sprintf("Dir(%d)", int(d))
+	}
+}
+
+var traceEnable = false
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	plane, err := readPlane(br)
 	if err != nil {
 		return err
 	}
 
-	count := solution(plane)
+	tr := NewTracer(plane)
+	if traceEnable {
+		tr.TraceFunc = func(format string, args ...any) {
+			fmt.Fprintf(bw, format+"\n", args...)
+		}
+	}
+
+	count := tr.Trace(Beam{0, 0, LeftToRight})
+
+	if debugEnable {
+		for _, row := range tr.visited {
+			log.Printf("%2d", row)
+		}
+	}
+
+	if traceEnable {
+		fmt.Fprintln(bw)
+		tr.WriteDirs(bw)
+		fmt.Fprintln(bw)
+		tr.WriteEnergized(bw)
+		fmt.Fprintln(bw)
+	}
+
 	fmt.Fprintln(bw, count)
 
 	return nil
 }
 
 func solution(plane [][]byte) int {
-	n := len(plane)
-	m := len(plane[0])
-	visited := makeMatrix[Dir](n, m)
+	return NewTracer(plane).Trace(Beam{0, 0, LeftToRight})
+}
 
-	var dfs func(i, j int, dir Dir)
+// Beam is the beam entering the cell (i,j) in the direction dir
+type Beam struct {
+	i, j int
+	dir  Dir
+}
 
-	doStep := func(i, j int, dir Dir) {
+// nextDirs returns the beam directions after the cell c. The second direction is zero
+// if the beam is not split
+func nextDirs(c byte, dir Dir) (Dir, Dir) {
+	switch c {
+	case '/':
 		switch dir {
 		case LeftToRight:
-			j++
+			return BottomToTop, 0
 		case RigthToLeft:
-			j--
+			return TopToBottom, 0
 		case TopToBottom:
-			i++
+			return RigthToLeft, 0
 		case BottomToTop:
-			i--
+			return LeftToRight, 0
 		}
-
-		if !(0 <= i && i < n && 0 <= j && j < m) {
-			return
+	case '\\':
+		switch dir {
+		case LeftToRight:
+			return TopToBottom, 0
+		case RigthToLeft:
+			return BottomToTop, 0
+		case TopToBottom:
+			return LeftToRight, 0
+		case BottomToTop:
+			return RigthToLeft, 0
 		}
-
-		if visited[i][j]&dir != 0 {
-			return
+	case '-':
+		switch dir {
+		case TopToBottom, BottomToTop:
+			return LeftToRight, RigthToLeft
 		}
+	case '|':
+		switch dir {
+		case LeftToRight, RigthToLeft:
+			return TopToBottom, BottomToTop
+		}
+	}
+
+	return dir, 0
+}
 
-		dfs(i, j, dir)
+// Tracer traces the beam by the queue of beams instead of recursion, so large planes
+// do not blow the stack
+type Tracer struct {
+	plane   [][]byte
+	n, m    int
+	visited [][]Dir
+	count   int
+	beams   queue.Queue[Beam]
+
+	// TraceFunc if not nil is called for each beam step
+	TraceFunc func(format string, args ...any)
+}
+
+func NewTracer(plane [][]byte) *Tracer {
+	n := len(plane)
+	m := len(plane[0])
+	return &Tracer{
+		plane:   plane,
+		n:       n,
+		m:       m,
+		visited: makeMatrix[Dir](n, m),
 	}
+}
 
-	dfs = func(i, j int, dir Dir) {
-		visited[i][j] |= dir
+// Trace returns the number of cells energized by the beam
+func (t *Tracer) Trace(start Beam) int {
+	clearMatrix(t.visited)
+	t.beams.Clear()
+	t.count = 0
 
-		switch plane[i][j] {
-		case '/':
-			switch dir {
-			case LeftToRight:
-				dir = BottomToTop
-			case RigthToLeft:
-				dir = TopToBottom
-			case TopToBottom:
-				dir = RigthToLeft
-			case BottomToTop:
-				dir = LeftToRight
-			}
-		case '\\':
-			switch dir {
-			case LeftToRight:
-				dir = TopToBottom
-			case RigthToLeft:
-				dir = BottomToTop
-			case TopToBottom:
-				dir = LeftToRight
-			case BottomToTop:
-				dir = RigthToLeft
-			}
-		case '-':
-			switch dir {
-			case TopToBottom, BottomToTop:
-				doStep(i, j, LeftToRight)
-				doStep(i, j, RigthToLeft)
-				return
-			}
-		case '|':
-			switch dir {
-			case LeftToRight, RigthToLeft:
-				doStep(i, j, TopToBottom)
-				doStep(i, j, BottomToTop)
-				return
+	t.push(start)
+
+	for t.beams.Size() > 0 {
+		b := t.beams.Pop()
+		c := t.plane[b.i][b.j]
+		dir1, dir2 := nextDirs(c, b.dir)
+
+		if t.TraceFunc != nil {
+			switch {
+			case dir2 != 0:
+				t.TraceFunc("(%d,%d) %v '%c' split: %v %v", b.i, b.j, b.dir, c, dir1, dir2)
+			case dir1 != b.dir:
+				t.TraceFunc("(%d,%d) %v '%c' reflect: %v", b.i, b.j, b.dir, c, dir1)
+			default:
+				t.TraceFunc("(%d,%d) %v '%c'", b.i, b.j, b.dir, c)
 			}
 		}
 
-		doStep(i, j, dir)
+		t.step(b.i, b.j, dir1)
+		if dir2 != 0 {
+			t.step(b.i, b.j, dir2)
+		}
+	}
+
+	return t.count
+}
+
+func (t *Tracer) step(i, j int, dir Dir) {
+	switch dir {
+	case LeftToRight:
+		j++
+	case RigthToLeft:
+		j--
+	case TopToBottom:
+		i++
+	case BottomToTop:
+		i--
 	}
+	t.push(Beam{i, j, dir})
+}
 
-	dfs(0, 0, LeftToRight)
+func (t *Tracer) push(b Beam) {
+	if !(0 <= b.i && b.i < t.n && 0 <= b.j && b.j < t.m) {
+		return
+	}
 
-	if debugEnable {
-		for _, row := range visited {
-			log.Printf("%2d", row)
-		}
+	if t.visited[b.i][b.j]&b.dir != 0 {
+		return
 	}
 
-	count := 0
-	for i := 0; i < n; i++ {
-		for j := 0; j < n; j++ {
-			if visited[i][j] != 0 {
-				count++
+	if t.visited[b.i][b.j] == 0 {
+		t.count++
+	}
+	t.visited[b.i][b.j] |= b.dir
+
+	t.beams.Push(b)
+}
+
+// WriteDirs writes the plane with the beam directions over the empty cells as in the
+// puzzle illustration (the number of directions if there are several)
+func (t *Tracer) WriteDirs(w io.Writer) error {
+	line := make([]byte, t.m)
+	for i, row := range t.plane {
+		for j, c := range row {
+			dirs := t.visited[i][j]
+			switch {
+			case c != '.' || dirs == 0:
+				line[j] = c
+			case bits.OnesCount(uint(dirs)) == 1:
+				line[j] = dirs.String()[0]
+			default:
+				line[j] = byte(bits.OnesCount(uint(dirs))) + '0'
 			}
 		}
+		if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return count
+// WriteEnergized writes the plane with the energized cells marked by '#'
+func (t *Tracer) WriteEnergized(w io.Writer) error {
+	line := make([]byte, t.m)
+	for _, row := range t.visited {
+		for j, dirs := range row {
+			line[j] = '.'
+			if dirs != 0 {
+				line[j] = '#'
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func readPlane(br io.Reader) ([][]byte, error) {
@@ -150,6 +276,14 @@ func makeMatrix[T any](n, m int) [][]T {
 	return matrix
 }
 
+func clearMatrix[T any](matrix [][]T) {
+	for _, row := range matrix {
+		for j := range row {
+			row[j] = *(new(T)) // zero
+		}
+	}
+}
+
 func run(r io.Reader, w io.Writer) (err error) {
 	br := bufio.NewReader(r)
 	bw := bufio.NewWriter(w)
@@ -164,6 +298,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.BoolVar(&traceEnable, "trace", traceEnable, "print each beam step and the energized cells")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -65,3 +65,36 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_trace(t *testing.T) {
+	traceEnable = true
+	defer func() { traceEnable = false }()
+
+	w := &bytes.Buffer{}
+	err := run(strings.NewReader(`.\.
+	.-.
+	...`), w)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	want := `(0,0) > '.'
+(0,1) > '\' reflect: v
+(1,1) v '-' split: > <
+(1,2) > '.'
+(1,0) < '.'
+
+>\.
+<->
+...
+
+##.
+###
+...
+
+5
+`
+	if got := w.String(); got != want {
+		t.Errorf("run() = \n%v, want \n%v", got, want)
+	}
+}
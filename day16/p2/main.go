@@ -1,12 +1,15 @@
 package main
 
 import (
+	"adventofcode-2023/lib/queue"
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"runtime"
 )
 
 type Dir int
@@ -18,130 +21,191 @@ const (
 	BottomToTop
 )
 
+var workerCount = runtime.NumCPU()
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	plane, err := readPlane(br)
 	if err != nil {
 		return err
 	}
 
-	count := solution(plane)
+	count := solution(plane, workerCount)
 	fmt.Fprintln(bw, count)
 
 	return nil
 }
 
-func solution(plane [][]byte) int {
-	n := len(plane)
-	m := len(plane[0])
-	visited := makeMatrix[Dir](n, m)
+// Entry is an entry point of the beam on the plane edge
+type Entry struct {
+	i, j int
+	dir  Dir
+}
+
+func getEntries(n, m int) []Entry {
+	entries := make([]Entry, 0, 2*(n+m))
+	for i := 0; i < n; i++ {
+		entries = append(entries, Entry{i, 0, LeftToRight}, Entry{i, m - 1, RigthToLeft})
+	}
+	for j := 0; j < m; j++ {
+		entries = append(entries, Entry{0, j, TopToBottom}, Entry{n - 1, j, BottomToTop})
+	}
+	return entries
+}
+
+// solution tries all entry points on the pool of workers and returns the maximum
+// number of energized cells
+func solution(plane [][]byte, workers int) int {
+	if workers < 1 {
+		workers = 1
+	}
 
-	var dfs func(i, j int, dir Dir)
+	entries := getEntries(len(plane), len(plane[0]))
+
+	jobs := make(chan Entry)
+	results := make(chan int, workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			e := NewEnergizer(plane)
+			count := 0
+			for en := range jobs {
+				count = max(count, e.Energize(en))
+			}
+			results <- count
+		}()
+	}
 
-	doStep := func(i, j int, dir Dir) {
+	for _, en := range entries {
+		jobs <- en
+	}
+	close(jobs)
+
+	count := 0
+	for w := 0; w < workers; w++ {
+		count = max(count, <-results)
+	}
+
+	return count
+}
+
+// Beam is the beam entering the cell (i,j) in the direction dir
+type Beam struct {
+	i, j int
+	dir  Dir
+}
+
+// nextDirs returns the beam directions after the cell c. The second direction is zero
+// if the beam is not split
+func nextDirs(c byte, dir Dir) (Dir, Dir) {
+	switch c {
+	case '/':
 		switch dir {
 		case LeftToRight:
-			j++
+			return BottomToTop, 0
 		case RigthToLeft:
-			j--
+			return TopToBottom, 0
 		case TopToBottom:
-			i++
+			return RigthToLeft, 0
 		case BottomToTop:
-			i--
+			return LeftToRight, 0
 		}
-
-		if !(0 <= i && i < n && 0 <= j && j < m) {
-			return
+	case '\\':
+		switch dir {
+		case LeftToRight:
+			return TopToBottom, 0
+		case RigthToLeft:
+			return BottomToTop, 0
+		case TopToBottom:
+			return LeftToRight, 0
+		case BottomToTop:
+			return RigthToLeft, 0
 		}
-
-		if visited[i][j]&dir != 0 {
-			return
+	case '-':
+		switch dir {
+		case TopToBottom, BottomToTop:
+			return LeftToRight, RigthToLeft
+		}
+	case '|':
+		switch dir {
+		case LeftToRight, RigthToLeft:
+			return TopToBottom, BottomToTop
 		}
-
-		dfs(i, j, dir)
 	}
 
-	dfs = func(i, j int, dir Dir) {
-		visited[i][j] |= dir
+	return dir, 0
+}
 
-		switch plane[i][j] {
-		case '/':
-			switch dir {
-			case LeftToRight:
-				dir = BottomToTop
-			case RigthToLeft:
-				dir = TopToBottom
-			case TopToBottom:
-				dir = RigthToLeft
-			case BottomToTop:
-				dir = LeftToRight
-			}
-		case '\\':
-			switch dir {
-			case LeftToRight:
-				dir = TopToBottom
-			case RigthToLeft:
-				dir = BottomToTop
-			case TopToBottom:
-				dir = LeftToRight
-			case BottomToTop:
-				dir = RigthToLeft
-			}
-		case '-':
-			switch dir {
-			case TopToBottom, BottomToTop:
-				doStep(i, j, LeftToRight)
-				doStep(i, j, RigthToLeft)
-				return
-			}
-		case '|':
-			switch dir {
-			case LeftToRight, RigthToLeft:
-				doStep(i, j, TopToBottom)
-				doStep(i, j, BottomToTop)
-				return
-			}
-		}
+// Energizer traces the beam by the queue of beams. Each worker has its own Energizer,
+// so the visited matrix and the queue are reused between entry points without locks
+type Energizer struct {
+	plane   [][]byte
+	n, m    int
+	visited [][]Dir
+	count   int
+	beams   queue.Queue[Beam]
+}
 
-		doStep(i, j, dir)
+func NewEnergizer(plane [][]byte) *Energizer {
+	n := len(plane)
+	m := len(plane[0])
+	return &Energizer{
+		plane:   plane,
+		n:       n,
+		m:       m,
+		visited: makeMatrix[Dir](n, m),
 	}
+}
 
-	calc := func() int {
-		count := 0
-		for i := 0; i < n; i++ {
-			for j := 0; j < n; j++ {
-				if visited[i][j] != 0 {
-					count++
-				}
-			}
+// Energize returns the number of cells energized by the beam from the entry point
+func (e *Energizer) Energize(en Entry) int {
+	clearMatrix(e.visited)
+	e.beams.Clear()
+	e.count = 0
+
+	e.push(Beam{en.i, en.j, en.dir})
+
+	for e.beams.Size() > 0 {
+		b := e.beams.Pop()
+		dir1, dir2 := nextDirs(e.plane[b.i][b.j], b.dir)
+
+		e.step(b.i, b.j, dir1)
+		if dir2 != 0 {
+			e.step(b.i, b.j, dir2)
 		}
-		return count
 	}
 
-	// TODO: Cейчас мы пробуем войти из каждой точки периметра.
-	// Можно исключить точки входа в которых были отраржения в предыдущих обходах.
+	return e.count
+}
 
-	count := 0
-	for i := 0; i < n; i++ {
-		clearMatrix(visited)
-		dfs(i, 0, LeftToRight)
-		count = max(count, calc())
+func (e *Energizer) step(i, j int, dir Dir) {
+	switch dir {
+	case LeftToRight:
+		j++
+	case RigthToLeft:
+		j--
+	case TopToBottom:
+		i++
+	case BottomToTop:
+		i--
+	}
+	e.push(Beam{i, j, dir})
+}
 
-		clearMatrix(visited)
-		dfs(i, m-1, RigthToLeft)
-		count = max(count, calc())
+func (e *Energizer) push(b Beam) {
+	if !(0 <= b.i && b.i < e.n && 0 <= b.j && b.j < e.m) {
+		return
 	}
 
-	for j := 0; j < m; j++ {
-		clearMatrix(visited)
-		dfs(0, j, TopToBottom)
-		count = max(count, calc())
+	if e.visited[b.i][b.j]&b.dir != 0 {
+		return
+	}
 
-		clearMatrix(visited)
-		dfs(n-1, j, BottomToTop)
-		count = max(count, calc())
+	if e.visited[b.i][b.j] == 0 {
+		e.count++
 	}
+	e.visited[b.i][b.j] |= b.dir
 
-	return count
+	e.beams.Push(b)
 }
 
 func max(a, b int) int {
@@ -198,6 +262,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.IntVar(&workerCount, "workers", workerCount, "number of workers")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -34,20 +35,26 @@ func Test_run(t *testing.T) {
 			false,
 			true,
 		},
-		// {
-		// 	"2",
-		// 	args{strings.NewReader(``)},
-		// 	``,
-		// 	false,
-		// 	true,
-		// },
-		// {
-		// 	"3",
-		// 	args{strings.NewReader(``)},
-		// 	``,
-		// 	false,
-		// 	true,
-		// },
+		{
+			"wide",
+			args{strings.NewReader(`......
+			......`)},
+			`6`,
+			false,
+			true,
+		},
+		{
+			"tall",
+			args{strings.NewReader(`..
+			..
+			.\
+			..
+			..
+			..`)},
+			`6`,
+			false,
+			true,
+		},
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
@@ -65,3 +72,27 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_solution(t *testing.T) {
+	plane, err := readPlane(strings.NewReader(`.|...\....
+	|.-.\.....
+	.....|-...
+	........|.
+	..........
+	.........\
+	..../.\\..
+	.-.-/..|..
+	.|....-|.\
+	..//.|....`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{0, 1, 2, 3, 16} {
+		t.Run(fmt.Sprint(workers), func(t *testing.T) {
+			if got := solution(plane, workers); got != 51 {
+				t.Errorf("solution() = %v, want %v", got, 51)
+			}
+		})
+	}
+}
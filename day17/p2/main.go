@@ -4,14 +4,60 @@ import (
 	"bufio"
 	"bytes"
 	"container/heap"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
+// Rules are the crucible movement rules
+type Rules struct {
+	MinDist     int     // minimum straight run
+	MaxDist     int     // maximum straight run
+	TurnPenalty int     // the heat loss added for each turn
+	Turns       Turns   // allowed turns after a straight run
+	Start       []Point // start cells, the top-left one if empty
+	Finish      []Point // finish cells, the bottom-right one if empty
+	AStar       bool    // use A* with the Manhattan distance heuristic
+}
+
+var rules = Rules{
+	MinDist: 4,
+	MaxDist: 10,
+	Turns:   TurnLeft | TurnRight,
+}
+
+var (
+	printStats = false
+	renderFile = "" // render the path to file, SVG if the file has .svg extension
+)
+
+func (r Rules) validate() error {
+	if r.MinDist < 1 {
+		return fmt.Errorf("min straight run must be positive, got %d", r.MinDist)
+	}
+	if r.MaxDist < r.MinDist {
+		return fmt.Errorf("max straight run must not be less than min, got %d < %d", r.MaxDist, r.MinDist)
+	}
+	if r.TurnPenalty < 0 {
+		return fmt.Errorf("turn penalty must not be negative, got %d", r.TurnPenalty)
+	}
+	if r.Turns == 0 {
+		return fmt.Errorf("no allowed turns")
+	}
+	return nil
+}
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
 	plane, err := readPlane(br)
 	if err != nil {
 		return err
@@ -23,7 +69,26 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 		}
 	}
 
-	graph := makeGraph(plane)
+	n := len(plane)
+	m := len(plane[0])
+
+	start := rules.Start
+	if len(start) == 0 {
+		start = []Point{{0, 0}}
+	}
+
+	finish := rules.Finish
+	if len(finish) == 0 {
+		finish = []Point{{n - 1, m - 1}}
+	}
+
+	for _, p := range append(start[:len(start):len(start)], finish...) {
+		if p.i < 0 || p.i >= n || p.j < 0 || p.j >= m {
+			return fmt.Errorf("point %v is out of plane %dx%d", p, n, m)
+		}
+	}
+
+	graph := makeGraph(plane, rules, start)
 
 	if debugEnable {
 		for i, edges := range graph {
@@ -31,31 +96,184 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 		}
 	}
 
-	total, path := searchMinPath(
-		graph,
-		[]int{0, 1},
-		[]int{len(graph) - 1, len(graph) - 2},
-	)
+	startIDs := make([]int, 0, len(start))
+	for k := range start {
+		startIDs = append(startIDs, n*m*4+k)
+	}
+
+	finishIDs := make([]int, 0, len(finish)*4+len(start))
+	for _, p := range finish {
+		for dir := Up; dir <= Left; dir++ {
+			finishIDs = append(finishIDs, getNodeID(m, p.i, p.j, dir))
+		}
+		for k, s := range start {
+			if s == p {
+				finishIDs = append(finishIDs, startIDs[k])
+			}
+		}
+	}
+
+	var heuristic func(id int) int
+	if rules.AStar {
+		heuristic = manhattanHeuristic(plane, finish)
+	}
+
+	total, path, expanded := searchMinPath(graph, startIDs, finishIDs, heuristic)
 
 	if debugEnable {
 		log.Println(path)
-		log.Println(pathIJ(path, len(plane[0])))
+		log.Println(pathIJ(path, n, m))
+	}
+
+	if renderFile != "" && path != nil {
+		if err := renderPath(renderFile, plane, pathSteps(path, n, m, start)); err != nil {
+			return err
+		}
 	}
 
 	fmt.Fprintln(bw, total)
+	if printStats {
+		fmt.Fprintln(bw, "expanded:", expanded)
+	}
 	return nil
 }
 
-func pathIJ(path []int, m int) [][2]int {
+func pathIJ(path []int, n, m int) [][2]int {
 	res := make([][2]int, 0, len(path))
 	for _, id := range path {
-		i := (id / 2) / m
-		j := (id / 2) % m
+		if id >= n*m*4 {
+			continue // virtual start node
+		}
+		i := (id / 4) / m
+		j := (id / 4) % m
 		res = append(res, [2]int{i, j})
 	}
 	return res
 }
 
+// Step is a path cell and the direction by which the path enters it
+type Step struct {
+	Point
+	Dir Dir
+}
+
+// pathSteps converts the path (from finish to start, as returned by searchMinPath) to
+// the cells from start to finish. The start cell is not included
+func pathSteps(path []int, n, m int, start []Point) []Step {
+	var steps []Step
+
+	var cur Point
+	for k := len(path) - 1; k >= 0; k-- {
+		id := path[k]
+		if id >= n*m*4 {
+			cur = start[id-n*m*4]
+			continue
+		}
+
+		dir := Dir(id % 4)
+		to := Point{(id / 4) / m, (id / 4) % m}
+		of := dirOffsets[dir]
+
+		for cur != to {
+			cur = Point{cur.i + of.i, cur.j + of.j}
+			steps = append(steps, Step{cur, dir})
+		}
+	}
+
+	return steps
+}
+
+var dirArrows = [...]byte{Up: '^', Right: '>', Down: 'v', Left: '<'}
+
+// renderASCII writes the heat-loss map with the path drawn by arrows
+func renderASCII(w io.Writer, plane [][]byte, steps []Step) error {
+	lines := makeMatrix[byte](len(plane), len(plane[0]))
+	for i, row := range plane {
+		for j, c := range row {
+			lines[i][j] = c + '0'
+		}
+	}
+
+	for _, st := range steps {
+		lines[st.i][st.j] = dirArrows[st.Dir]
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const svgCellSize = 10
+
+// renderSVG writes the heat map of the plane with the path drawn on top
+func renderSVG(w io.Writer, plane [][]byte, steps []Step) error {
+	n := len(plane)
+	m := len(plane[0])
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		m*svgCellSize, n*svgCellSize, m*svgCellSize, n*svgCellSize)
+
+	for i, row := range plane {
+		for j, c := range row {
+			// from light yellow (0) to dark red (9)
+			g := 230 - int(c)*25
+			b := 180 - int(c)*20
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="rgb(230,%d,%d)"/>`+"\n",
+				j*svgCellSize, i*svgCellSize, svgCellSize, svgCellSize, g, b)
+		}
+	}
+
+	if len(steps) > 0 {
+		center := func(p Point) (int, int) {
+			return p.j*svgCellSize + svgCellSize/2, p.i*svgCellSize + svgCellSize/2
+		}
+
+		// the start cell is the previous one to the first step
+		of := dirOffsets[steps[0].Dir]
+		x, y := center(Point{steps[0].i - of.i, steps[0].j - of.j})
+
+		fmt.Fprintf(bw, `<polyline fill="none" stroke="blue" stroke-width="%d" points="%d,%d`, svgCellSize/4, x, y)
+		for _, st := range steps {
+			x, y := center(st.Point)
+			fmt.Fprintf(bw, " %d,%d", x, y)
+		}
+		fmt.Fprintln(bw, `"/>`)
+
+		fmt.Fprintf(bw, `<circle cx="%d" cy="%d" r="%d" fill="green"/>`+"\n", x, y, svgCellSize/3)
+		x, y = center(steps[len(steps)-1].Point)
+		fmt.Fprintf(bw, `<circle cx="%d" cy="%d" r="%d" fill="blue"/>`+"\n", x, y, svgCellSize/3)
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+
+	return bw.Flush()
+}
+
+// renderPath renders the path to the file. The format is selected by the file extension
+func renderPath(fileName string, plane [][]byte, steps []Step) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if strings.EqualFold(filepath.Ext(fileName), ".svg") {
+		return renderSVG(f, plane, steps)
+	}
+
+	return renderASCII(f, plane, steps)
+}
+
 func restorePath(nodes []Item, id int) []int {
 	var path []int
 	for id != -1 {
@@ -65,7 +283,45 @@ func restorePath(nodes []Item, id int) []int {
 	return path
 }
 
-func searchMinPath(graph [][]Edge, start, finish []int) (int, []int) {
+// manhattanHeuristic returns the admissible A* heuristic: the Manhattan distance to the
+// nearest finish cell multiplied by the minimal heat loss of a cell
+func manhattanHeuristic(plane [][]byte, finish []Point) func(id int) int {
+	m := len(plane[0])
+
+	minLoss := math.MaxInt
+	for _, row := range plane {
+		for _, c := range row {
+			minLoss = min(minLoss, int(c))
+		}
+	}
+
+	virtualID := len(plane) * m * 4
+
+	return func(id int) int {
+		if id >= virtualID {
+			return 0
+		}
+
+		i := (id / 4) / m
+		j := (id / 4) % m
+
+		h := math.MaxInt
+		for _, p := range finish {
+			h = min(h, abs(p.i-i)+abs(p.j-j))
+		}
+
+		return h * minLoss
+	}
+}
+
+// searchMinPath searches the minimal path by Dijkstra algorithm or by A* if the
+// heuristic is not nil. It returns the path length, the path (from finish to start)
+// and the number of expanded nodes
+func searchMinPath(graph [][]Edge, start, finish []int, heuristic func(id int) int) (int, []int, int) {
+	if heuristic == nil {
+		heuristic = func(int) int { return 0 }
+	}
+
 	nodes := make([]Item, len(graph))
 	for i := range nodes {
 		nodes[i] = Item{
@@ -76,19 +332,27 @@ func searchMinPath(graph [][]Edge, start, finish []int) (int, []int) {
 		}
 	}
 
+	isFinish := make([]bool, len(graph))
+	for _, id := range finish {
+		isFinish[id] = true
+	}
+
 	frontier := &PriorityQueue{}
 
 	for _, id := range start {
 		nodes[id].dist = 0
+		nodes[id].prio = heuristic(id)
 		heap.Push(frontier, &nodes[id])
 	}
 
+	expanded := 0
+
 	for frontier.Len() > 0 {
 		node := heap.Pop(frontier).(*Item)
-		for _, id := range finish {
-			if node.id == id {
-				return node.dist, restorePath(nodes, id)
-			}
+		expanded++
+
+		if isFinish[node.id] {
+			return node.dist, restorePath(nodes, node.id), expanded
 		}
 
 		for _, edge := range graph[node.id] {
@@ -97,17 +361,18 @@ func searchMinPath(graph [][]Edge, start, finish []int) (int, []int) {
 
 			if dist < neig.dist {
 				neig.dist = dist
+				neig.prio = dist + heuristic(neig.id)
 				neig.prev = node.id
 				if neig.index == -1 {
 					heap.Push(frontier, neig)
-				} else if dist < neig.dist {
+				} else {
 					heap.Fix(frontier, neig.index)
 				}
 			}
 		}
 	}
 
-	return -1, nil
+	return -1, nil, expanded
 }
 
 type Edge struct {
@@ -115,106 +380,176 @@ type Edge struct {
 	dist   int
 }
 
+// Dir is the direction of the last straight run
 type Dir byte
 
 const (
-	V Dir = iota
-	H
+	Up Dir = iota
+	Right
+	Down
+	Left
 )
 
+var dirOffsets = [...]Point{Up: {-1, 0}, Right: {0, 1}, Down: {1, 0}, Left: {0, -1}}
+
+// Turns is the set of allowed turns
+type Turns byte
+
 const (
-	minDist = 4
-	maxDist = 10
+	TurnLeft Turns = 1 << iota
+	TurnRight
+	TurnBack
 )
 
-func getNodeID(m, i, j int, dir Dir) int {
-	return (i*m+j)*2 + int(dir)
+func (t Turns) String() string {
+	var sb strings.Builder
+	for _, it := range [...]struct {
+		turn Turns
+		c    byte
+	}{{TurnLeft, 'l'}, {TurnRight, 'r'}, {TurnBack, 'u'}} {
+		if t&it.turn != 0 {
+			sb.WriteByte(it.c)
+		}
+	}
+	return sb.String()
 }
 
-func getVNeigs(plane [][]byte, i, j int) []Edge {
-	n := len(plane)
-	m := len(plane[0])
+// Set parses the turn set like "lr" (l - left, r - right, u - U-turn)
+func (t *Turns) Set(s string) error {
+	var v Turns
+	for _, c := range s {
+		switch c {
+		case 'l':
+			v |= TurnLeft
+		case 'r':
+			v |= TurnRight
+		case 'u':
+			v |= TurnBack
+		default:
+			return fmt.Errorf("unknown turn '%c', l, r or u expected", c)
+		}
+	}
+	*t = v
+	return nil
+}
 
-	neigs := make([]Edge, 0, 14)
+// dirs returns the directions allowed after the run in direction dir
+func (t Turns) dirs(dir Dir) []Dir {
+	dirs := make([]Dir, 0, 3)
+	if t&TurnLeft != 0 {
+		dirs = append(dirs, (dir+3)%4)
+	}
+	if t&TurnRight != 0 {
+		dirs = append(dirs, (dir+1)%4)
+	}
+	if t&TurnBack != 0 {
+		dirs = append(dirs, (dir+2)%4)
+	}
+	return dirs
+}
 
-	{
-		w := 0
-		k, i2 := 1, i+1
-		for ; k < minDist && i2 < n; k, i2 = k+1, i2+1 {
-			w += int(plane[i2][j])
-		}
-		for ; k <= maxDist && i2 < n; k, i2 = k+1, i2+1 {
-			w += int(plane[i2][j])
-			idx := getNodeID(m, i2, j, V)
-			neigs = append(neigs, Edge{neigID: idx, dist: w})
-		}
+type Point struct {
+	i, j int
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%d,%d)", p.i, p.j)
+}
+
+func (p *Point) Set(s string) error {
+	const op = "Point.Set"
+
+	v := strings.Split(s, ",")
+	if len(v) != 2 {
+		return fmt.Errorf("%s: two comma-separated numbers are required: %s", op, s)
 	}
 
-	{
-		w := 0
-		k, i2 := 1, i-1
-		for ; k < minDist && i2 >= 0; k, i2 = k+1, i2-1 {
-			w += int(plane[i2][j])
-		}
-		for ; k <= maxDist && i2 >= 0; k, i2 = k+1, i2-1 {
-			w += int(plane[i2][j])
-			idx := getNodeID(m, i2, j, V)
-			neigs = append(neigs, Edge{neigID: idx, dist: w})
-		}
+	i, err := strconv.Atoi(strings.TrimPrefix(v[0], "("))
+	if err != nil {
+		return fmt.Errorf("%s: bad first number: %w", op, err)
 	}
 
-	return neigs
+	j, err := strconv.Atoi(strings.TrimSuffix(v[1], ")"))
+	if err != nil {
+		return fmt.Errorf("%s: bad second number %w", op, err)
+	}
+
+	p.i = i
+	p.j = j
+
+	return nil
 }
 
-func getHNeigs(plane [][]byte, i, j int) []Edge {
-	// n := len(plane)
-	m := len(plane[0])
+// Points is a flag.Value that collects repeated points
+type Points []Point
 
-	neigs := make([]Edge, 0, 14)
+func (ps Points) String() string {
+	return fmt.Sprint([]Point(ps))
+}
 
-	{
-		w := 0
-		k, j2 := 1, j+1
-		for ; k < minDist && j2 < m; k, j2 = k+1, j2+1 {
-			w += int(plane[i][j2])
-		}
-		for ; k <= maxDist && j2 < m; k, j2 = k+1, j2+1 {
-			w += int(plane[i][j2])
-			idx := getNodeID(m, i, j2, H)
-			neigs = append(neigs, Edge{neigID: idx, dist: w})
-		}
+func (ps *Points) Set(s string) error {
+	var p Point
+	if err := p.Set(s); err != nil {
+		return err
 	}
+	*ps = append(*ps, p)
+	return nil
+}
 
-	{
-		w := 0
-		k, j2 := 1, j-1
-		for ; k < minDist && j2 >= 0; k, j2 = k+1, j2-1 {
-			w += int(plane[i][j2])
-		}
-		for ; k <= maxDist && j2 >= 0; k, j2 = k+1, j2-1 {
-			w += int(plane[i][j2])
-			idx := getNodeID(m, i, j2, H)
-			neigs = append(neigs, Edge{neigID: idx, dist: w})
-		}
+func getNodeID(m, i, j int, dir Dir) int {
+	return (i*m+j)*4 + int(dir)
+}
+
+// getNeigs returns the edges for the straight runs in direction dir from the cell (i,j)
+func getNeigs(plane [][]byte, r Rules, i, j int, dir Dir, penalty int) []Edge {
+	n := len(plane)
+	m := len(plane[0])
+
+	valid := func(i, j int) bool {
+		return 0 <= i && i < n && 0 <= j && j < m
+	}
+
+	of := dirOffsets[dir]
+	neigs := make([]Edge, 0, r.MaxDist-r.MinDist+1)
+
+	w := penalty
+	k, i2, j2 := 1, i+of.i, j+of.j
+	for ; k < r.MinDist && valid(i2, j2); k, i2, j2 = k+1, i2+of.i, j2+of.j {
+		w += int(plane[i2][j2])
+	}
+	for ; k <= r.MaxDist && valid(i2, j2); k, i2, j2 = k+1, i2+of.i, j2+of.j {
+		w += int(plane[i2][j2])
+		idx := getNodeID(m, i2, j2, dir)
+		neigs = append(neigs, Edge{neigID: idx, dist: w})
 	}
 
 	return neigs
 }
 
-func makeGraph(plane [][]byte) [][]Edge {
+// makeGraph makes the graph of nodes (cell, direction of the last run). The last
+// len(start) nodes are the virtual ones, each connected to its start cell in all
+// directions
+func makeGraph(plane [][]byte, r Rules, start []Point) [][]Edge {
 	n := len(plane)
 	m := len(plane[0])
 
-	graph := make([][]Edge, n*m*2)
+	graph := make([][]Edge, n*m*4+len(start))
 
-	var idx int
 	for i, row := range plane {
 		for j := range row {
-			idx = getNodeID(m, i, j, H)
-			graph[idx] = getVNeigs(plane, i, j)
+			for dir := Up; dir <= Left; dir++ {
+				idx := getNodeID(m, i, j, dir)
+				for _, dir2 := range r.Turns.dirs(dir) {
+					graph[idx] = append(graph[idx], getNeigs(plane, r, i, j, dir2, r.TurnPenalty)...)
+				}
+			}
+		}
+	}
 
-			idx = getNodeID(m, i, j, V)
-			graph[idx] = getHNeigs(plane, i, j)
+	for k, p := range start {
+		virtualID := n*m*4 + k
+		for dir := Up; dir <= Left; dir++ {
+			graph[virtualID] = append(graph[virtualID], getNeigs(plane, r, p.i, p.j, dir, 0)...)
 		}
 	}
 
@@ -252,6 +587,20 @@ func makeMatrix[T any](n, m int) [][]T {
 	return matrix
 }
 
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
 func run(r io.Reader, w io.Writer) (err error) {
 	br := bufio.NewReader(r)
 	bw := bufio.NewWriter(w)
@@ -266,6 +615,18 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.IntVar(&rules.MinDist, "min", rules.MinDist, "minimum straight run")
+	flag.IntVar(&rules.MaxDist, "max", rules.MaxDist, "maximum straight run")
+	flag.IntVar(&rules.TurnPenalty, "turn-penalty", rules.TurnPenalty, "heat loss added for each turn")
+	flag.Var(&rules.Turns, "turns", "allowed turns: l - left, r - right, u - U-turn")
+	flag.Var((*Points)(&rules.Start), "start", "start cell `(i,j)`, may be repeated (default (0,0))")
+	flag.Var((*Points)(&rules.Finish), "finish", "finish cell `(i,j)`, may be repeated (default (n-1,m-1))")
+	flag.BoolVar(&rules.AStar, "astar", rules.AStar, "use A* search with the Manhattan distance heuristic")
+	flag.BoolVar(&printStats, "stats", printStats, "print the number of expanded nodes")
+	flag.StringVar(&renderFile, "render", renderFile, "render the path over the map to `file` (SVG if *.svg, ASCII otherwise)")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -276,7 +637,8 @@ var _, debugEnable = os.LookupEnv("DEBUG")
 // An Item is something we manage in a priority queue.
 type Item struct {
 	id   int
-	dist int // The priority of the item in the queue.
+	dist int
+	prio int // The priority of the item in the queue.
 	prev int
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
@@ -288,7 +650,7 @@ type PriorityQueue []*Item
 func (pq PriorityQueue) Len() int { return len(pq) }
 
 func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].dist < pq[j].dist
+	return pq[i].prio < pq[j].prio
 }
 
 func (pq PriorityQueue) Swap(i, j int) {
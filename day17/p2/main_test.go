@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/xml"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -72,3 +74,244 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_rules(t *testing.T) {
+	const example = `2413432311323
+	3215453535623
+	3255245654254
+	3446585845452
+	4546657867536
+	1438598798454
+	4457876987766
+	3637877979653
+	4654967986887
+	4564679986453
+	1224686865563
+	2546548887735
+	4322674655533`
+
+	type args struct {
+		r io.Reader
+	}
+	tests := []struct {
+		name    string
+		rules   Rules
+		stats   bool
+		args    args
+		wantW   string
+		wantErr bool
+	}{
+		{
+			"crucible",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight},
+			false,
+			args{strings.NewReader(example)},
+			`102`,
+			false,
+		},
+		{
+			"crucible A*",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight, AStar: true},
+			false,
+			args{strings.NewReader(example)},
+			`102`,
+			false,
+		},
+		{
+			"ultra crucible A*",
+			Rules{MinDist: 4, MaxDist: 10, Turns: TurnLeft | TurnRight, AStar: true},
+			false,
+			args{strings.NewReader(example)},
+			`94`,
+			false,
+		},
+		{
+			"turn penalty",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight, TurnPenalty: 100},
+			false,
+			args{strings.NewReader(`119
+			991
+			991
+			991`)},
+			`113`,
+			false,
+		},
+		{
+			"U-turn",
+			Rules{MinDist: 2, MaxDist: 3, Turns: TurnLeft | TurnRight | TurnBack, Finish: []Point{{0, 1}}},
+			false,
+			args{strings.NewReader(`1911`)},
+			`21`,
+			false,
+		},
+		{
+			"no U-turn",
+			Rules{MinDist: 2, MaxDist: 3, Turns: TurnLeft | TurnRight, Finish: []Point{{0, 1}}},
+			false,
+			args{strings.NewReader(`1911`)},
+			`-1`,
+			false,
+		},
+		{
+			"start is finish",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight, Start: []Point{{1, 1}}, Finish: []Point{{1, 1}}},
+			false,
+			args{strings.NewReader(`111
+			111`)},
+			`0`,
+			false,
+		},
+		{
+			"stats",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight},
+			true,
+			args{strings.NewReader(`12`)},
+			"2\nexpanded: 2",
+			false,
+		},
+		{
+			"finish out of plane",
+			Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight, Finish: []Point{{2, 0}}},
+			false,
+			args{strings.NewReader(`12`)},
+			``,
+			true,
+		},
+		{
+			"bad rules",
+			Rules{MinDist: 3, MaxDist: 1, Turns: TurnLeft | TurnRight},
+			false,
+			args{strings.NewReader(`12`)},
+			``,
+			true,
+		},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedRules := rules
+			rules = tt.rules
+			printStats = tt.stats
+			defer func() { rules = savedRules; printStats = false }()
+			w := &bytes.Buffer{}
+			if err := run(tt.args.r, w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+// examplePath returns the example plane and the steps of its minimal path
+func examplePath(t *testing.T) ([][]byte, []Step) {
+	t.Helper()
+
+	plane, err := readPlane(strings.NewReader(`2413432311323
+	3215453535623
+	3255245654254
+	3446585845452
+	4546657867536
+	1438598798454
+	4457876987766
+	3637877979653
+	4654967986887
+	4564679986453
+	1224686865563
+	2546548887735
+	4322674655533`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := len(plane)
+	m := len(plane[0])
+	r := Rules{MinDist: 1, MaxDist: 3, Turns: TurnLeft | TurnRight}
+	start := []Point{{0, 0}}
+
+	graph := makeGraph(plane, r, start)
+	finish := make([]int, 0, 4)
+	for dir := Up; dir <= Left; dir++ {
+		finish = append(finish, getNodeID(m, n-1, m-1, dir))
+	}
+
+	_, path, _ := searchMinPath(graph, []int{n * m * 4}, finish, nil)
+
+	return plane, pathSteps(path, n, m, start)
+}
+
+func Test_renderASCII(t *testing.T) {
+	plane, steps := examplePath(t)
+
+	w := &bytes.Buffer{}
+	if err := renderASCII(w, plane, steps); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `2>>34^>>>1323
+32v>>>35v5623
+32552456v>>54
+3446585845v52
+4546657867v>6
+14385987984v4
+44578769877v6
+36378779796v>
+465496798688v
+456467998645v
+12246868655<v
+25465488877v5
+43226746555v>
+`
+	if got := w.String(); got != want {
+		t.Errorf("renderASCII() = \n%v, want \n%v", got, want)
+	}
+}
+
+func Test_renderSVG(t *testing.T) {
+	plane, steps := examplePath(t)
+
+	w := &bytes.Buffer{}
+	if err := renderSVG(w, plane, steps); err != nil {
+		t.Fatal(err)
+	}
+
+	type circle struct {
+		CX   string `xml:"cx,attr"`
+		CY   string `xml:"cy,attr"`
+		Fill string `xml:"fill,attr"`
+	}
+
+	var svg struct {
+		Width    string     `xml:"width,attr"`
+		Height   string     `xml:"height,attr"`
+		ViewBox  string     `xml:"viewBox,attr"`
+		Rects    []struct{} `xml:"rect"`
+		Polyline struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polyline"`
+		Circles []circle `xml:"circle"`
+	}
+	if err := xml.Unmarshal(w.Bytes(), &svg); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+
+	if svg.Width != "130" || svg.Height != "130" || svg.ViewBox != "0 0 130 130" {
+		t.Errorf("size = %s x %s, viewBox %q, want 130 x 130, viewBox \"0 0 130 130\"", svg.Width, svg.Height, svg.ViewBox)
+	}
+	if len(svg.Rects) != 13*13 {
+		t.Errorf("got %d cells, want %d", len(svg.Rects), 13*13)
+	}
+
+	// the cell centers of the path drawn by renderASCII from the start to the finish
+	wantPoints := "5,5 15,5 25,5 25,15 35,15 45,15 55,15 55,5 65,5 75,5 85,5 85,15 85,25 95,25 105,25 105,35 105,45 115,45 115,55 115,65 115,75 125,75 125,85 125,95 125,105 115,105 115,115 115,125 125,125"
+	if svg.Polyline.Points != wantPoints {
+		t.Errorf("points = %q, want %q", svg.Polyline.Points, wantPoints)
+	}
+
+	wantCircles := []circle{{"5", "5", "green"}, {"125", "125", "blue"}}
+	if !reflect.DeepEqual(svg.Circles, wantCircles) {
+		t.Errorf("circles = %+v, want %+v", svg.Circles, wantCircles)
+	}
+}
@@ -2,11 +2,21 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
 	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Dir byte
@@ -18,151 +28,411 @@ const (
 	R
 )
 
+func (d Dir) String() string {
+	return string("UDLR"[d])
+}
+
+// opposite returns the direction back
+func (d Dir) opposite() Dir {
+	return d ^ 1
+}
+
 type PlaneItem struct {
-	dir Dir
-	len int
+	dir   Dir
+	len   int
+	color uint32 // RGB
 }
 
 type Point struct {
 	i, j int
 }
 
-func getNextPoint(p1, p2, p3 Point) Point {
-	const op = "getNextPoint"
+func (p Point) move(d Dir, n int) (Point, error) {
+	var err error
+	switch d {
+	case U:
+		p.i, err = subChecked(p.i, n)
+	case D:
+		p.i, err = addChecked(p.i, n)
+	case L:
+		p.j, err = subChecked(p.j, n)
+	case R:
+		p.j, err = addChecked(p.j, n)
+	}
+	return p, err
+}
+
+var (
+	ErrOverflow         = errors.New("integer overflow")
+	ErrEmptyPlan        = errors.New("empty plan")
+	ErrZeroLength       = errors.New("zero-length segment")
+	ErrBacktracking     = errors.New("back-tracking segment")
+	ErrNotClosed        = errors.New("loop is not closed")
+	ErrSelfIntersection = errors.New("loop self-intersects")
+)
 
-	v1 := p2.i - p1.i
-	h1 := p2.j - p1.j
+func addChecked(a, b int) (int, error) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
 
-	v2 := p3.i - p2.i
-	h2 := p3.j - p2.j
+func subChecked(a, b int) (int, error) {
+	if b == math.MinInt {
+		return 0, ErrOverflow
+	}
+	return addChecked(a, -b)
+}
 
-	if h1 > 0 {
-		if v2 > 0 {
-			return Point{p2.i, p2.j + 1}
-		} else if v2 < 0 {
-			return Point{p2.i, p2.j}
-		}
-	} else if h1 < 0 {
-		if v2 > 0 {
-			return Point{p2.i + 1, p2.j + 1}
-		} else if v2 < 0 {
-			return Point{p2.i + 1, p2.j}
+func mulChecked(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	c := a * b
+	if c/b != a || a == -1 && b == math.MinInt || b == -1 && a == math.MinInt {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+// getPath returns the corners of the loop beginning with the one after the origin. It
+// checks the segments and the loop closing
+func getPath(plane []PlaneItem) ([]Point, error) {
+	if len(plane) == 0 {
+		return nil, ErrEmptyPlan
+	}
+
+	path := make([]Point, 0, len(plane))
+
+	var p Point
+	for k, v := range plane {
+		if v.len <= 0 {
+			return nil, fmt.Errorf("%w: %d: %v %d", ErrZeroLength, k+1, v.dir, v.len)
 		}
-	} else if v1 > 0 {
-		if h2 > 0 {
-			return Point{p2.i, p2.j + 1}
-		} else if h2 < 0 {
-			return Point{p2.i + 1, p2.j + 1}
+		prev := plane[(k+len(plane)-1)%len(plane)]
+		if v.dir == prev.dir.opposite() {
+			return nil, fmt.Errorf("%w: %d: %v after %v", ErrBacktracking, k+1, v.dir, prev.dir)
 		}
-	} else if v1 < 0 {
-		if h2 > 0 {
-			return Point{p2.i, p2.j}
-		} else if h2 < 0 {
-			return Point{p2.i + 1, p2.j}
+
+		var err error
+		p, err = p.move(v.dir, v.len)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", k+1, err)
 		}
+		path = append(path, p)
 	}
 
-	panic(fmt.Sprintf("%s: bad sequention: %v %v %v (%d, %d, %d, %d)", op, p1, p2, p3, v1, h1, v2, h2))
+	if p != (Point{}) {
+		return nil, fmt.Errorf("%w: ends at %v", ErrNotClosed, p)
+	}
+
+	return path, nil
 }
 
-func getPath(plane []PlaneItem) []Point {
-	path := make([]Point, 0, len(plane))
+// checkIntersections returns an error if any non-adjacent segments have a common
+// point. The axis-aligned segment is equal to its bounding box, so the segments
+// intersect if their boxes do
+func checkIntersections(path []Point) error {
+	n := len(path)
+	seg := func(k int) (Point, Point) {
+		a, b := path[(k+n-1)%n], path[k]
+		return Point{min(a.i, b.i), min(a.j, b.j)}, Point{max(a.i, b.i), max(a.j, b.j)}
+	}
 
-	var i, j int
-
-	for _, v := range plane {
-		switch v.dir {
-		case U:
-			i -= v.len
-		case D:
-			i += v.len
-		case L:
-			j -= v.len
-		case R:
-			j += v.len
+	for k1 := 0; k1 < n; k1++ {
+		lo1, hi1 := seg(k1)
+		for k2 := k1 + 2; k2 < n; k2++ {
+			if k1 == 0 && k2 == n-1 {
+				continue // adjacent through the origin
+			}
+			lo2, hi2 := seg(k2)
+			if lo1.i <= hi2.i && lo2.i <= hi1.i && lo1.j <= hi2.j && lo2.j <= hi1.j {
+				return fmt.Errorf("%w: segments %d and %d", ErrSelfIntersection, k1+1, k2+1)
+			}
 		}
-		path = append(path, Point{i, j})
 	}
-
-	return path
+	return nil
 }
 
-func getPoints(path []Point) []Point {
-	points := make([]Point, 0, len(path))
+// calcArea returns the number of the cubic meters dug out: the trench and the
+// interior. By the shoelace formula and Pick's theorem it is A + B/2 + 1, where A is
+// the polygon area over the corner centers and B is the perimeter
+func calcArea(path []Point) (int, error) {
+	var twiceArea, perimeter int
 
 	p1 := path[len(path)-1]
-	p2 := path[0]
-	for i := 1; i < len(path); i++ {
-		p3 := path[i]
-		points = append(points, getNextPoint(p1, p2, p3))
+	for _, p2 := range path {
+		a, err := mulChecked(p1.i, p2.j)
+		if err != nil {
+			return 0, err
+		}
+		b, err := mulChecked(p2.i, p1.j)
+		if err != nil {
+			return 0, err
+		}
+		cross, err := subChecked(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if twiceArea, err = addChecked(twiceArea, cross); err != nil {
+			return 0, err
+		}
+
+		side := abs(p2.i-p1.i) + abs(p2.j-p1.j) // checked by getPath
+		if perimeter, err = addChecked(perimeter, side); err != nil {
+			return 0, err
+		}
+
 		p1 = p2
-		p2 = p3
 	}
-	points = append(points, getNextPoint(p1, p2, path[0]))
 
-	return points
+	if twiceArea == math.MinInt {
+		return 0, ErrOverflow
+	}
+
+	total, err := addChecked(abs(twiceArea), perimeter)
+	if err != nil {
+		return 0, err
+	}
+	return total/2 + 1, nil
 }
 
-func reverse[T any](a []T) []T {
-	for i, j := 0, len(a)-1; i < j; i, j = i+1, j-1 {
-		a[i], a[j] = a[j], a[i]
+const (
+	renderMaxSize  = 1000 // the longest image side in pixels
+	renderCellSize = 10   // the largest cell size in pixels
+)
+
+// canvas maps the plan cells to the image pixels
+type canvas struct {
+	minI, minJ    int
+	scale         float64 // pixels per cell
+	width, height int
+}
+
+// newCanvas returns the canvas which fits the path into renderMaxSize pixels
+func newCanvas(path []Point) canvas {
+	lo, hi := path[0], path[0]
+	for _, p := range path {
+		lo = Point{min(lo.i, p.i), min(lo.j, p.j)}
+		hi = Point{max(hi.i, p.i), max(hi.j, p.j)}
+	}
+
+	// the cells, not the distances
+	n := float64(hi.i-lo.i) + 1
+	m := float64(hi.j-lo.j) + 1
+
+	scale := math.Min(renderCellSize, renderMaxSize/math.Max(n, m))
+	return canvas{
+		minI:   lo.i,
+		minJ:   lo.j,
+		scale:  scale,
+		width:  int(math.Ceil(m*scale - 1e-9)),
+		height: int(math.Ceil(n*scale - 1e-9)),
 	}
-	return a
 }
 
-func calcArea(points []Point) int {
-	total := 0
+// xy returns the pixel coordinates of the cell center
+func (c canvas) xy(p Point) (float64, float64) {
+	x := (float64(p.j-c.minJ) + 0.5) * c.scale
+	y := (float64(p.i-c.minI) + 0.5) * c.scale
+	return x, y
+}
+
+// strokeWidth returns the trench width in pixels, at least one pixel
+func (c canvas) strokeWidth() float64 {
+	return math.Max(c.scale, 1)
+}
 
-	p1 := points[len(points)-1]
-	for i := 0; i < len(points); i++ {
-		p2 := points[i]
-		if p1.j == p2.j {
-			total += (p2.i - p1.i) * p1.j
+func rgb(c uint32) color.RGBA {
+	return color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xff}
+}
+
+var lagoonColor = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+
+// renderSVG writes the filled lagoon and the trench segments in their colors
+func renderSVG(w io.Writer, plane []PlaneItem, path []Point) error {
+	c := newCanvas(path)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		c.width, c.height, c.width, c.height)
+
+	fmt.Fprintf(bw, `<polygon fill="#%02x%02x%02x" points="`, lagoonColor.R, lagoonColor.G, lagoonColor.B)
+	for k, p := range path {
+		if k > 0 {
+			fmt.Fprint(bw, " ")
 		}
+		x, y := c.xy(p)
+		fmt.Fprintf(bw, "%.1f,%.1f", x, y)
+	}
+	fmt.Fprintln(bw, `"/>`)
+
+	p1 := path[len(path)-1]
+	for k, p2 := range path {
+		x1, y1 := c.xy(p1)
+		x2, y2 := c.xy(p2)
+		fmt.Fprintf(bw, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#%06x" stroke-width="%.1f" stroke-linecap="square"/>`+"\n",
+			x1, y1, x2, y2, plane[k].color, c.strokeWidth())
 		p1 = p2
 	}
 
-	return abs(total)
+	fmt.Fprintln(bw, "</svg>")
+
+	return bw.Flush()
 }
 
-func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	plane, err := readPlane(br)
+// renderImage returns the filled lagoon and the trench segments in their colors
+func renderImage(plane []PlaneItem, path []Point) *image.RGBA {
+	c := newCanvas(path)
+
+	img := image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// the lagoon by the scanlines crossing the vertical segments
+	xs := make([]float64, 0, len(path))
+	for y := 0; y < c.height; y++ {
+		yc := float64(y) + 0.5
+
+		xs = xs[:0]
+		p1 := path[len(path)-1]
+		for _, p2 := range path {
+			x1, y1 := c.xy(p1)
+			_, y2 := c.xy(p2)
+			if p1.j == p2.j && math.Min(y1, y2) <= yc && yc < math.Max(y1, y2) {
+				xs = append(xs, x1)
+			}
+			p1 = p2
+		}
+		sort.Float64s(xs)
+
+		for k := 0; k+1 < len(xs); k += 2 {
+			for x := int(math.Round(xs[k])); x < int(math.Round(xs[k+1])); x++ {
+				img.SetRGBA(x, y, lagoonColor)
+			}
+		}
+	}
+
+	// the trench segments as the rectangles of the stroke width
+	half := c.strokeWidth() / 2
+	p1 := path[len(path)-1]
+	for k, p2 := range path {
+		x1, y1 := c.xy(p1)
+		x2, y2 := c.xy(p2)
+		r := image.Rect(
+			int(math.Floor(math.Min(x1, x2)-half)), int(math.Floor(math.Min(y1, y2)-half)),
+			int(math.Ceil(math.Max(x1, x2)+half)), int(math.Ceil(math.Max(y1, y2)+half)),
+		)
+		draw.Draw(img, r, image.NewUniform(rgb(plane[k].color)), image.Point{}, draw.Src)
+		p1 = p2
+	}
+
+	return img
+}
+
+// renderPlan renders the lagoon to the file. The format is selected by the file
+// extension: SVG or PNG
+func renderPlan(fileName string, plane []PlaneItem, path []Point) (err error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext != ".svg" && ext != ".png" {
+		return fmt.Errorf("render %s: unknown format, want .svg or .png", fileName)
+	}
+
+	f, err := os.Create(fileName)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
 
-	path := getPath(plane)
+	if ext == ".svg" {
+		return renderSVG(f, plane, path)
+	}
+	return png.Encode(f, renderImage(plane, path))
+}
 
-	if debugEnable {
-		log.Println("path:", path)
+var (
+	decodeMode = "hex"
+	renderFile = "" // render the lagoon to file, SVG if *.svg, PNG if *.png
+)
+
+func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	plane, err := readPlane(br, decodeMode)
+	if err != nil {
+		return err
 	}
 
-	points1 := getPoints(path)
+	path, err := getPath(plane)
+	if err != nil {
+		return err
+	}
 
 	if debugEnable {
-		log.Println("points:", points1)
+		log.Println("path:", path)
 	}
 
-	area1 := calcArea(points1)
-
-	reverse(path)
-	points2 := getPoints(path)
+	if err := checkIntersections(path); err != nil {
+		return err
+	}
 
-	if debugEnable {
-		log.Println("points:", points2)
+	area, err := calcArea(path)
+	if err != nil {
+		return fmt.Errorf("area: %w", err)
 	}
 
-	area2 := calcArea(points2)
+	if renderFile != "" {
+		if err := renderPlan(renderFile, plane, path); err != nil {
+			return err
+		}
+	}
 
-	fmt.Fprintln(bw, max(area1, area2))
+	fmt.Fprintln(bw, area)
 
 	return nil
 }
 
-func readPlane(r io.Reader) ([]PlaneItem, error) {
+func parseDir(s string) (Dir, error) {
+	switch s {
+	case "U":
+		return U, nil
+	case "D":
+		return D, nil
+	case "L":
+		return L, nil
+	case "R":
+		return R, nil
+	}
+	return 0, fmt.Errorf("unknown dir '%s'", s)
+}
+
+// parseColor parses `(#XXXXXX)`
+func parseColor(s string) (uint32, error) {
+	if len(s) != 9 || s[:2] != "(#" || s[8] != ')' {
+		return 0, fmt.Errorf("invalid color '%s'", s)
+	}
+	v, err := strconv.ParseUint(s[2:8], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid color '%s': %w", s, err)
+	}
+	return uint32(v), nil
+}
+
+// readPlane reads the plan. In the direct mode the direction and the length are
+// taken as is, in the hex mode they are decoded from the color: 5 hex digits of the
+// length and the direction digit (0 R, 1 D, 2 L, 3 U)
+func readPlane(r io.Reader, mode string) ([]PlaneItem, error) {
+	if mode != "direct" && mode != "hex" {
+		return nil, fmt.Errorf("unknown decode mode %q", mode)
+	}
+
 	var plane []PlaneItem
 
-	for {
+	for k := 1; ; k++ {
 		var (
 			it  PlaneItem
 			dir string
@@ -175,28 +445,23 @@ func readPlane(r io.Reader) ([]PlaneItem, error) {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%d: %w", k, err)
+		}
+
+		it.color, err = parseColor(clr)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", k, err)
+		}
+
+		if mode == "direct" {
+			it.dir, err = parseDir(dir)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", k, err)
+			}
+			plane = append(plane, it)
+			continue
 		}
 
-		// // TODO: parseDir()
-
-		// switch dir {
-		// case "U":
-		// 	it.dir = U
-		// case "D":
-		// 	it.dir = D
-		// case "L":
-		// 	it.dir = L
-		// case "R":
-		// 	it.dir = R
-		// default:
-		// 	return nil, fmt.Errorf("unknown dir '%s'", dir)
-		// }
-
-		// TODO: parseColor()
-
-		// 012345678
-		// (#XXXXXD)
 		switch clr[7] {
 		case '0':
 			it.dir = R
@@ -206,14 +471,11 @@ func readPlane(r io.Reader) ([]PlaneItem, error) {
 			it.dir = L
 		case '3':
 			it.dir = U
+		default:
+			return nil, fmt.Errorf("%d: unknown dir digit '%c'", k, clr[7])
 		}
 
-		len64, err := strconv.ParseInt(clr[2:7], 16, 64)
-		if err != nil {
-			return nil, err
-		}
-
-		it.len = int(len64)
+		it.len = int(it.color >> 4)
 		plane = append(plane, it)
 	}
 
@@ -234,6 +496,11 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&decodeMode, "decode", decodeMode, "instruction decoding: direct or hex")
+	flag.StringVar(&renderFile, "render", renderFile, "render the lagoon to `file` (SVG if *.svg, PNG if *.png)")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"image"
+	"image/color"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -69,3 +74,180 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_decode(t *testing.T) {
+	const example = `R 6 (#70c710)
+D 5 (#0dc571)
+L 2 (#5713f0)
+D 2 (#d2c081)
+R 2 (#59c680)
+D 2 (#411b91)
+L 5 (#8ceee2)
+U 2 (#caa173)
+L 1 (#1b58a2)
+U 2 (#caa171)
+R 2 (#7807d2)
+U 3 (#a77fa3)
+L 2 (#015232)
+U 2 (#7a21e3)`
+
+	tests := []struct {
+		name    string
+		decode  string
+		input   string
+		wantW   string
+		wantErr error
+	}{
+		{"direct", "direct", example, `62`, nil},
+		{"hex", "hex", example, `952408144115`, nil},
+		{"square", "direct", "R 2 (#000000)\nD 2 (#000000)\nL 2 (#000000)\nU 2 (#000000)", `9`, nil},
+		{"collinear", "direct", "R 1 (#000000)\nR 1 (#000000)\nD 2 (#000000)\nL 2 (#000000)\nU 2 (#000000)", `9`, nil},
+		{"not closed", "direct", "R 2 (#000000)\nD 2 (#000000)\nL 2 (#000000)\nU 1 (#000000)", ``, ErrNotClosed},
+		{"zero length", "direct", "R 2 (#000000)\nD 0 (#000000)\nD 2 (#000000)\nL 2 (#000000)\nU 2 (#000000)", ``, ErrZeroLength},
+		{"backtracking", "direct", "R 2 (#000000)\nL 1 (#000000)\nD 2 (#000000)\nL 1 (#000000)\nU 2 (#000000)", ``, ErrBacktracking},
+		{"backtracking wrap", "direct", "R 2 (#000000)\nL 2 (#000000)", ``, ErrBacktracking},
+		{
+			"self-intersection",
+			"direct",
+			"R 4 (#000000)\nD 2 (#000000)\nL 2 (#000000)\nU 4 (#000000)\nL 2 (#000000)\nD 2 (#000000)",
+			``,
+			ErrSelfIntersection,
+		},
+		{
+			"overflow",
+			"direct",
+			"R 4294967296 (#000000)\nD 4294967296 (#000000)\nL 4294967296 (#000000)\nU 4294967296 (#000000)",
+			``,
+			ErrOverflow,
+		},
+		{"empty", "direct", ``, ``, ErrEmptyPlan},
+		{"bad dir", "direct", "X 2 (#000000)", ``, nil},
+		{"bad hex dir", "hex", "R 2 (#000007)", ``, nil},
+		{"bad color", "direct", "R 2 #000000", ``, nil},
+		{"bad mode", "bin", example, ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeMode = tt.decode
+			defer func() { decodeMode = "hex" }()
+
+			w := &bytes.Buffer{}
+			err := run(strings.NewReader(tt.input), w)
+			if wantErr := tt.wantW == ""; (err != nil) != wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("run() error = %v, want %v", err, tt.wantErr)
+			}
+			if gotW := w.String(); tt.wantW != "" && strings.TrimSpace(gotW) != tt.wantW {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func Test_render(t *testing.T) {
+	const example = `R 6 (#70c710)
+D 5 (#0dc571)
+L 2 (#5713f0)
+D 2 (#d2c081)
+R 2 (#59c680)
+D 2 (#411b91)
+L 5 (#8ceee2)
+U 2 (#caa173)
+L 1 (#1b58a2)
+U 2 (#caa171)
+R 2 (#7807d2)
+U 3 (#a77fa3)
+L 2 (#015232)
+U 2 (#7a21e3)`
+
+	readPath := func(mode string) ([]PlaneItem, []Point) {
+		plane, err := readPlane(strings.NewReader(example), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := getPath(plane)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plane, path
+	}
+
+	t.Run("svg", func(t *testing.T) {
+		plane, path := readPath("direct")
+
+		w := &bytes.Buffer{}
+		if err := renderSVG(w, plane, path); err != nil {
+			t.Fatal(err)
+		}
+
+		got := w.String()
+		for _, want := range []string{
+			`width="70" height="100"`,
+			`<polygon fill="#d0d0d0" points="65.0,5.0 65.0,55.0 `,
+			`<line x1="5.0" y1="5.0" x2="65.0" y2="5.0" stroke="#70c710" stroke-width="10.0"`,
+			`<line x1="5.0" y1="25.0" x2="5.0" y2="5.0" stroke="#7a21e3" stroke-width="10.0"`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("renderSVG() = %v, want %v in it", got, want)
+			}
+		}
+	})
+
+	t.Run("png", func(t *testing.T) {
+		plane, path := readPath("direct")
+		img := renderImage(plane, path)
+
+		if got := img.Bounds().Size(); got != (image.Point{70, 100}) {
+			t.Errorf("size = %v, want 70x100", got)
+		}
+
+		tests := []struct {
+			x, y int
+			want color.RGBA
+		}{
+			{30, 5, rgb(0x70c710)},  // the first segment
+			{65, 30, rgb(0x0dc571)}, // the second one
+			{40, 40, lagoonColor},
+			{5, 45, color.RGBA{0xff, 0xff, 0xff, 0xff}}, // outside
+		}
+		for _, tt := range tests {
+			if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+				t.Errorf("(%d,%d) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		plane, path := readPath("hex")
+		img := renderImage(plane, path)
+
+		size := img.Bounds().Size()
+		if size.X > renderMaxSize || size.Y > renderMaxSize || max(size.X, size.Y) != renderMaxSize {
+			t.Errorf("size = %v, want the longest side %d", size, renderMaxSize)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"lagoon.svg", "lagoon.PNG"} {
+			renderFile = filepath.Join(dir, name)
+			w := &bytes.Buffer{}
+			err := run(strings.NewReader(example), w)
+			renderFile = ""
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if st, err := os.Stat(filepath.Join(dir, name)); err != nil || st.Size() == 0 {
+				t.Errorf("%s is not written: %v", name, err)
+			}
+		}
+
+		renderFile = filepath.Join(dir, "lagoon.gif")
+		defer func() { renderFile = "" }()
+		if err := run(strings.NewReader(example), &bytes.Buffer{}); err == nil {
+			t.Errorf("run() with gif: want error")
+		}
+	})
+}
@@ -4,34 +4,102 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"unicode"
 	"unsafe"
 )
 
-type Color int
+// Set is the number of cubes of each colour shown at once
+type Set map[string]int
 
-const (
-	ColorRed Color = iota
-	ColorGreen
-	ColorBulue
-)
+type Game struct {
+	ID     int
+	Sets   []Set
+	Colors []string // in the order of appearance
+}
+
+// Bag is the number of cubes of each colour in the bag
+type Bag map[string]int
 
-func calcPower(data [][3]int) int {
-	var r, g, b int
+// String returns the bag in the form of the -bag flag with the colours sorted
+func (b Bag) String() string {
+	colors := make([]string, 0, len(b))
+	for c := range b {
+		colors = append(colors, c)
+	}
+	sort.Strings(colors)
+	return b.Format(colors)
+}
+
+// Format returns the bag with the colours in the given order
+func (b Bag) Format(colors []string) string {
+	var sb strings.Builder
+	for k, c := range colors {
+		if k > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%s=%d", c, b[c])
+	}
+	return sb.String()
+}
+
+// Set parses `red=12,green=13,blue=14`
+func (b *Bag) Set(s string) error {
+	bag := Bag{}
+	for _, item := range strings.Split(s, ",") {
+		color, count, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || color == "" {
+			return fmt.Errorf("invalid bag item %q, want color=count", item)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid count of %s: %q", color, count)
+		}
+		bag[color] = n
+	}
+	*b = bag
+	return nil
+}
+
+// Possible reports whether the game is possible with the bag. The colours missing in
+// the bag have no cubes
+func (g *Game) Possible(bag Bag) bool {
+	for _, set := range g.Sets {
+		for c, n := range set {
+			if n > bag[c] {
+				return false
+			}
+		}
+	}
+	return true
+}
 
-	for _, set := range data {
-		r = max(r, set[ColorRed])
-		g = max(g, set[ColorGreen])
-		b = max(b, set[ColorBulue])
+// MinBag returns the fewest cubes of each colour which make the game possible
+func (g *Game) MinBag() Bag {
+	bag := Bag{}
+	for _, set := range g.Sets {
+		for c, n := range set {
+			bag[c] = max(bag[c], n)
+		}
 	}
+	return bag
+}
 
-	return r * g * b
+// Power returns the product of the cube numbers of the colours
+func (b Bag) Power(colors []string) int {
+	power := 1
+	for _, c := range colors {
+		power *= b[c]
+	}
+	return power
 }
 
 func max(a, b int) int {
@@ -41,38 +109,40 @@ func max(a, b int) int {
 	return a
 }
 
-func check(data [][3]int) bool {
-	for _, set := range data {
-		if set[ColorRed] > 12 {
-			return false
-		}
-		if set[ColorGreen] > 13 {
-			return false
-		}
-		if set[ColorBulue] > 14 {
-			return false
+func (g *Game) hasColor(color string) bool {
+	for _, c := range g.Colors {
+		if c == color {
+			return true
 		}
 	}
-	return true
+	return false
 }
 
-func parseLine(s []byte) ([][3]int, error) {
+func parseLine(s []byte) (*Game, error) {
 	scan := NewScanner(bytes.NewReader(s))
 
-	// skip: Game #:
-	scan.Scan()
-	scan.Scan()
+	// Game #:
+	if !scan.Scan() || scan.Text() != "Game" {
+		return nil, errors.New("'Game' not found")
+	}
+	if !scan.Scan() || !bytes.HasSuffix(scan.Bytes(), []byte(":")) {
+		return nil, errors.New("game id not found")
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(scan.Text(), ":"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid game id: %w", err)
+	}
 
-	var data [][3]int
+	g := &Game{ID: id}
 
 mainLoop:
 	for {
-		var set [3]int
+		set := Set{}
 
 		for {
 			n, err := scan.Int()
 			if err != nil {
-				if err == io.EOF {
+				if err == io.EOF && len(set) == 0 {
 					break mainLoop
 				}
 				return nil, err
@@ -82,7 +152,7 @@ mainLoop:
 				return nil, errors.New("can't read color")
 			}
 
-			color := unsafeString(scan.Bytes()) // <color>[,]
+			color := scan.Text() // <color>[,;]
 			delim := color[len(color)-1]
 
 			if !unicode.IsLetter(rune(delim)) {
@@ -91,54 +161,123 @@ mainLoop:
 				delim = 0
 			}
 
-			switch color {
-			case "red":
-				set[ColorRed] = n
-			case "green":
-				set[ColorGreen] = n
-			case "blue":
-				set[ColorBulue] = n
-			default:
-				return nil, fmt.Errorf("unknown color '%s'", color)
+			if color == "" {
+				return nil, errors.New("empty color")
 			}
+			if !g.hasColor(color) {
+				g.Colors = append(g.Colors, color)
+			}
+			set[color] += n
 
 			if delim != ',' {
 				break
 			}
 		}
 
-		data = append(data, set)
+		g.Sets = append(g.Sets, set)
 	}
 
-	return data, nil
+	return g, nil
 }
 
-func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	var sum int
-	var lineNo int
+// Games is the games with the colours in the order of appearance
+type Games struct {
+	List   []*Game
+	Colors []string
+}
 
-	for {
-		lineNo++
+func readGames(br *bufio.Reader) (*Games, error) {
+	games := &Games{}
+	seen := map[string]bool{}
+
+	for lineNo := 1; ; lineNo++ {
 		s, isPrefix, err := br.ReadLine()
 		if isPrefix {
-			return fmt.Errorf("%d: line too long", lineNo)
+			return nil, fmt.Errorf("%d: line too long", lineNo)
 		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return fmt.Errorf("%d: %w", lineNo, err)
+			return nil, fmt.Errorf("%d: %w", lineNo, err)
+		}
+
+		if len(bytes.TrimSpace(s)) == 0 {
+			continue
 		}
 
-		data, err := parseLine(s)
+		g, err := parseLine(s)
 		if err != nil {
-			return fmt.Errorf("%d: can't parse: %w", lineNo, err)
+			return nil, fmt.Errorf("%d: can't parse: %w", lineNo, err)
 		}
 
-		sum += calcPower(data)
+		for _, c := range g.Colors {
+			if !seen[c] {
+				seen[c] = true
+				games.Colors = append(games.Colors, c)
+			}
+		}
+		games.List = append(games.List, g)
 	}
 
-	fmt.Fprintln(bw, sum)
+	return games, nil
+}
+
+// MinBag returns the fewest cubes which make all the games possible
+func (gs *Games) MinBag(ids []int) (Bag, error) {
+	bag := Bag{}
+	for _, id := range ids {
+		found := false
+		for _, g := range gs.List {
+			if g.ID != id {
+				continue
+			}
+			found = true
+			for c, n := range g.MinBag() {
+				bag[c] = max(bag[c], n)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("game %d not found", id)
+		}
+	}
+	return bag, nil
+}
+
+var (
+	bagLimits = Bag{"red": 12, "green": 13, "blue": 14}
+	queryIDs  []int
+)
+
+func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	games, err := readGames(br)
+	if err != nil {
+		return err
+	}
+
+	if debugEnable {
+		log.Println("colors:", games.Colors)
+	}
+
+	if queryIDs != nil {
+		bag, err := games.MinBag(queryIDs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "%s power %d\n", bag.Format(games.Colors), bag.Power(games.Colors))
+		return nil
+	}
+
+	var idSum, powerSum int
+	for _, g := range games.List {
+		if g.Possible(bagLimits) {
+			idSum += g.ID
+		}
+		powerSum += g.MinBag().Power(games.Colors)
+	}
+
+	fmt.Fprintln(bw, idSum)
+	fmt.Fprintln(bw, powerSum)
 	return nil
 }
 
@@ -156,6 +295,21 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.Var(&bagLimits, "bag", "cubes in the bag")
+	flag.Func("min-bag", "print the minimal bag for the comma separated game IDs", func(s string) error {
+		queryIDs = []int{}
+		for _, item := range strings.Split(s, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil {
+				return err
+			}
+			queryIDs = append(queryIDs, id)
+		}
+		return nil
+	})
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
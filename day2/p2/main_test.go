@@ -25,7 +25,8 @@ func Test_run(t *testing.T) {
 			Game 3: 8 green, 6 blue, 20 red; 5 blue, 4 red, 13 green; 5 green, 1 red
 			Game 4: 1 green, 3 red, 6 blue; 3 green, 6 red; 3 green, 15 blue, 14 red
 			Game 5: 6 red, 1 blue, 3 green; 2 blue, 1 red, 2 green`)},
-			`2286`,
+			`8
+2286`,
 			false,
 			true,
 		},
@@ -60,3 +61,81 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+const example = `Game 1: 3 blue, 4 red; 1 red, 2 green, 6 blue; 2 green
+Game 2: 1 blue, 2 green; 3 green, 4 blue, 1 red; 1 green, 1 blue
+Game 3: 8 green, 6 blue, 20 red; 5 blue, 4 red, 13 green; 5 green, 1 red
+Game 4: 1 green, 3 red, 6 blue; 3 green, 6 red; 3 green, 15 blue, 14 red
+Game 5: 6 red, 1 blue, 3 green; 2 blue, 1 red, 2 green`
+
+func Test_run_bag(t *testing.T) {
+	tests := []struct {
+		name    string
+		bag     string
+		query   []int
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{"bigger bag", "red=20,green=13,blue=15", nil, example, "15\n2286", false},
+		{"no blue", "red=12,green=13", nil, example, "0\n2286", false},
+		{"min bag", "red=12,green=13,blue=14", []int{1, 3}, example, "blue=6,red=20,green=13 power 1560", false},
+		{"min bag one", "red=12,green=13,blue=14", []int{5}, example, "blue=2,red=6,green=3 power 36", false},
+		{"unknown game", "red=12,green=13,blue=14", []int{6}, example, ``, true},
+		{
+			"new colours",
+			"yellow=3,red=1",
+			nil,
+			"Game 7: 2 yellow, 1 red; 3 yellow\nGame 9: 1 purple",
+			"7\n0",
+			false,
+		},
+		{"bad game", "red=1", nil, "Gme 1: 1 red", ``, true},
+		{"no color", "red=1", nil, "Game 1: 1", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := bagLimits
+			if err := bagLimits.Set(tt.bag); err != nil {
+				t.Fatal(err)
+			}
+			queryIDs = tt.query
+			defer func() { bagLimits = saved; queryIDs = nil }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) && !tt.wantErr {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func TestBag_Set(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"red=12,green=13,blue=14", "blue=14,green=13,red=12", false},
+		{" red=1 ", "red=1", false},
+		{"red", "", true},
+		{"=1", "", true},
+		{"red=-1", "", true},
+		{"red=x", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var b Bag
+			if err := b.Set(tt.in); (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := b.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
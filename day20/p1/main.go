@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -239,22 +240,266 @@ func (b *Broadcaster) Process(p Pulse, s Sender) {
 	}
 }
 
-func parseModule(s string) (Module, error) {
+// base is a common part of the simple modules
+type base struct {
+	name    string
+	outputs []string
+}
+
+func (b *base) Name() string {
+	return b.name
+}
+
+func (b *base) Outputs() []string {
+	return b.outputs
+}
+
+func (b *base) RegInput(name string) { /* nothing */ }
+
+func (b *base) send(s Sender, val PulseType) {
+	for _, output := range b.outputs {
+		s.Send(Pulse{From: b.name, To: output, Val: val})
+	}
+}
+
+// Inverter sends the inverted pulse for each received one
+type Inverter struct {
+	base
+}
+
+var _ Module = (*Inverter)(nil)
+
+func NewInverter(name string, outputs []string) *Inverter {
+	return &Inverter{base{name: name, outputs: outputs}}
+}
+
+func (inv *Inverter) Process(p Pulse, s Sender) {
+	val := HightPulse
+	if p.Val == HightPulse {
+		val = LowPulse
+	}
+	inv.send(s, val)
+}
+
+// Delay is a one-pulse delay line. For each received pulse it sends the previous
+// received one (initially low) and keeps the new one
+type Delay struct {
+	base
+	prev PulseType
+}
+
+var _ Module = (*Delay)(nil)
+
+func NewDelay(name string, outputs []string) *Delay {
+	return &Delay{
+		base: base{name: name, outputs: outputs},
+		prev: LowPulse,
+	}
+}
+
+func (d *Delay) Process(p Pulse, s Sender) {
+	val := d.prev
+	d.prev = p.Val
+	d.send(s, val)
+}
+
+// Counter counts the received pulses and passes them through
+type Counter struct {
+	base
+	LowCount   int
+	HightCount int
+}
+
+var _ Module = (*Counter)(nil)
+
+func NewCounter(name string, outputs []string) *Counter {
+	return &Counter{base: base{name: name, outputs: outputs}}
+}
+
+func (c *Counter) Process(p Pulse, s Sender) {
+	switch p.Val {
+	case LowPulse:
+		c.LowCount++
+	case HightPulse:
+		c.HightCount++
+	}
+	c.send(s, p.Val)
+}
+
+// Probe records all received pulses and passes them through. Probe without outputs is
+// an output sink
+type Probe struct {
+	base
+	Pulses []Pulse
+}
+
+var _ Module = (*Probe)(nil)
+
+func NewProbe(name string, outputs []string) *Probe {
+	return &Probe{base: base{name: name, outputs: outputs}}
+}
+
+func (pr *Probe) Process(p Pulse, s Sender) {
+	pr.Pulses = append(pr.Pulses, p)
+	pr.send(s, p.Val)
+}
+
+// Gate is a multi-input logic gate. It remembers the last pulse from each input
+// (initially low) and after each received pulse sends the result of its function
+type Gate struct {
+	base
+	inputs map[string]PulseType
+	hight  int
+	fn     func(hight, total int) bool
+}
+
+var _ Module = (*Gate)(nil)
+
+// NewAndGate returns the gate that sends hight pulse if all inputs are hight
+func NewAndGate(name string, outputs []string) *Gate {
+	return newGate(name, outputs, func(hight, total int) bool { return hight == total })
+}
+
+// NewOrGate returns the gate that sends hight pulse if any input is hight
+func NewOrGate(name string, outputs []string) *Gate {
+	return newGate(name, outputs, func(hight, _ int) bool { return hight > 0 })
+}
+
+func newGate(name string, outputs []string, fn func(hight, total int) bool) *Gate {
+	return &Gate{
+		base:   base{name: name, outputs: outputs},
+		inputs: map[string]PulseType{},
+		fn:     fn,
+	}
+}
+
+// RegInput registers the input once, a repeated edge keeps its last pulse
+func (g *Gate) RegInput(name string) {
+	if _, ok := g.inputs[name]; ok {
+		return
+	}
+	g.inputs[name] = LowPulse
+}
+
+func (g *Gate) Process(p Pulse, s Sender) {
+	if g.inputs[p.From] != p.Val {
+		if p.Val == HightPulse {
+			g.hight++
+		} else {
+			g.hight--
+		}
+		g.inputs[p.From] = p.Val
+	}
+
+	val := LowPulse
+	if g.fn(g.hight, len(g.inputs)) {
+		val = HightPulse
+	}
+	g.send(s, val)
+}
+
+// ModuleConstructor creates a module with the given name (without the kind prefix)
+// and outputs
+type ModuleConstructor func(name string, outputs []string) (Module, error)
+
+type moduleKind struct {
+	prefix string
+	exact  bool
+	ctor   ModuleConstructor
+}
+
+// Registry maps module descriptions to module constructors. A kind is selected either
+// by the exact module name (like broadcaster) or by the name prefix (like % or &).
+// The longest matching prefix wins.
+type Registry struct {
+	kinds []moduleKind
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register registers a module kind by name prefix. If the prefix already registered,
+// Register replaces its constructor
+func (r *Registry) Register(prefix string, ctor ModuleConstructor) {
+	r.add(moduleKind{prefix: prefix, ctor: ctor})
+}
+
+// RegisterName registers a module kind by exact module name. If the name already
+// registered, RegisterName replaces its constructor
+func (r *Registry) RegisterName(name string, ctor ModuleConstructor) {
+	r.add(moduleKind{prefix: name, exact: true, ctor: ctor})
+}
+
+func (r *Registry) add(kind moduleKind) {
+	for i := range r.kinds {
+		if r.kinds[i].prefix == kind.prefix && r.kinds[i].exact == kind.exact {
+			r.kinds[i] = kind
+			return
+		}
+	}
+
+	r.kinds = append(r.kinds, kind)
+
+	// exact names first, then longest prefixes
+	sort.SliceStable(r.kinds, func(i, j int) bool {
+		a, b := r.kinds[i], r.kinds[j]
+		if a.exact != b.exact {
+			return a.exact
+		}
+		return len(a.prefix) > len(b.prefix)
+	})
+}
+
+// New creates a module by its description name (with the kind prefix)
+func (r *Registry) New(name string, outputs []string) (Module, error) {
+	for _, kind := range r.kinds {
+		if kind.exact {
+			if name == kind.prefix {
+				return kind.ctor(name, outputs)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(name, kind.prefix) {
+			if len(name) == len(kind.prefix) {
+				return nil, fmt.Errorf("no module name")
+			}
+			return kind.ctor(name[len(kind.prefix):], outputs)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown module type: %s", name)
+}
+
+// Parse parses a module description like "%a -> b, c". The arrow and outputs may be
+// omitted, in this case the module kind constructor decides whether it is an error
+func (r *Registry) Parse(s string) (Module, error) {
 	var (
 		name    string
 		arrow   string
 		outputs []string
 	)
 
-	r := strings.NewReader(s)
-	_, err := fmt.Fscan(r, &name, &arrow)
+	sr := strings.NewReader(s)
+	_, err := fmt.Fscan(sr, &name)
 	if err != nil {
 		return nil, err
 	}
 
+	// the arrow may be omitted for a module without outputs (like "?out")
+	_, err = fmt.Fscan(sr, &arrow)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if err == nil && arrow != "->" {
+		return nil, fmt.Errorf("'->' expected, got '%s'", arrow)
+	}
+
 	for {
 		var output string
-		_, err = fmt.Fscan(r, &output)
+		_, err = fmt.Fscan(sr, &output)
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -269,29 +514,65 @@ func parseModule(s string) (Module, error) {
 		outputs = append(outputs, output)
 	}
 
-	if len(outputs) == 0 {
-		return nil, fmt.Errorf("no any outputs")
-	}
+	return r.New(name, outputs)
+}
 
-	switch name[0] {
-	case '%':
-		if len(name) < 2 {
-			return nil, fmt.Errorf("no module name")
-		}
-		return NewFlipFlop(name[1:], outputs), nil
-	case '&':
-		if len(name) < 2 {
-			return nil, fmt.Errorf("no module name")
+func needOutputs(ctor func(name string, outputs []string) Module) ModuleConstructor {
+	return func(name string, outputs []string) (Module, error) {
+		if len(outputs) == 0 {
+			return nil, fmt.Errorf("no any outputs")
 		}
-		return NewConjuction(name[1:], outputs), nil
-	default: // broadcast
-		if name != broadcasterName {
-			return nil, fmt.Errorf("unknown module type: %s", name)
-		}
-		return NewBroadcaster(outputs), nil
+		return ctor(name, outputs), nil
 	}
 }
 
+// DefaultRegistry knows the puzzle modules and the custom ones:
+//
+//	broadcaster  Broadcaster
+//	%name        FlipFlop
+//	&name        Conjunction
+//	!name        Inverter
+//	~name        Delay
+//	#name        Counter
+//	?name        Probe (outputs may be omitted)
+//	*name        AND gate
+//	+name        OR gate
+var DefaultRegistry = func() *Registry {
+	r := NewRegistry()
+	r.RegisterName(broadcasterName, needOutputs(func(_ string, outputs []string) Module {
+		return NewBroadcaster(outputs)
+	}))
+	r.Register("%", needOutputs(func(name string, outputs []string) Module {
+		return NewFlipFlop(name, outputs)
+	}))
+	r.Register("&", needOutputs(func(name string, outputs []string) Module {
+		return NewConjuction(name, outputs)
+	}))
+	r.Register("!", needOutputs(func(name string, outputs []string) Module {
+		return NewInverter(name, outputs)
+	}))
+	r.Register("~", needOutputs(func(name string, outputs []string) Module {
+		return NewDelay(name, outputs)
+	}))
+	r.Register("#", needOutputs(func(name string, outputs []string) Module {
+		return NewCounter(name, outputs)
+	}))
+	r.Register("?", func(name string, outputs []string) (Module, error) {
+		return NewProbe(name, outputs), nil
+	})
+	r.Register("*", needOutputs(func(name string, outputs []string) Module {
+		return NewAndGate(name, outputs)
+	}))
+	r.Register("+", needOutputs(func(name string, outputs []string) Module {
+		return NewOrGate(name, outputs)
+	}))
+	return r
+}()
+
+func parseModule(s string) (Module, error) {
+	return DefaultRegistry.Parse(s)
+}
+
 func _run(sc *bufio.Scanner, bw *bufio.Writer) error {
 	schema := NewSchema()
 
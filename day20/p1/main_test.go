@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -64,3 +66,158 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_customModules(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		probe string
+		want  []PulseType
+	}{
+		{
+			"inverter",
+			`broadcaster -> inv
+			!inv -> out
+			?out`,
+			"out",
+			[]PulseType{HightPulse, HightPulse},
+		},
+		{
+			"delay",
+			`broadcaster -> ff
+			%ff -> dl
+			~dl -> out
+			?out`,
+			"out",
+			[]PulseType{LowPulse, HightPulse},
+		},
+		{
+			"and",
+			`broadcaster -> a, b
+			%a -> and
+			%b -> and
+			*and -> out
+			?out`,
+			"out",
+			[]PulseType{LowPulse, HightPulse, LowPulse, LowPulse},
+		},
+		{
+			"or",
+			`broadcaster -> a, b
+			%a -> or
+			%b -> or
+			+or -> out
+			?out`,
+			"out",
+			[]PulseType{HightPulse, HightPulse, HightPulse, LowPulse},
+		},
+		{
+			"counter",
+			`broadcaster -> cnt
+			#cnt -> out
+			?out`,
+			"out",
+			[]PulseType{LowPulse, LowPulse},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := NewSchema()
+			for _, line := range strings.Split(tt.input, "\n") {
+				mod, err := parseModule(strings.TrimSpace(line))
+				if err != nil {
+					t.Fatalf("parseModule(%q) error = %v", line, err)
+				}
+				schema.AddModule(mod)
+			}
+			schema.Prepare()
+			schema.PressButton()
+			schema.PressButton()
+
+			probe, ok := schema.modules[tt.probe].(*Probe)
+			if !ok {
+				t.Fatalf("module %s is not a probe", tt.probe)
+			}
+			got := make([]PulseType, len(probe.Pulses))
+			for i, p := range probe.Pulses {
+				got[i] = p.Val
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("probe pulses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	r.Register("%", needOutputs(func(name string, outputs []string) Module {
+		return NewFlipFlop(name, outputs)
+	}))
+	r.Register("%%", needOutputs(func(name string, outputs []string) Module {
+		return NewInverter(name, outputs)
+	}))
+
+	tests := []struct {
+		name     string
+		s        string
+		wantName string
+		wantType string
+		wantErr  bool
+	}{
+		{"prefix", "%a -> b", "a", "*main.FlipFlop", false},
+		{"longest prefix", "%%a -> b", "a", "*main.Inverter", false},
+		{"no name", "% -> b", "", "", true},
+		{"no outputs", "%a ->", "", "", true},
+		{"unknown", "&a -> b", "", "", true},
+		{"no arrow", "%a b", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Parse(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Registry.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name() != tt.wantName {
+				t.Errorf("Registry.Parse() name = %v, want %v", got.Name(), tt.wantName)
+			}
+			if gotType := fmt.Sprintf("%T", got); gotType != tt.wantType {
+				t.Errorf("Registry.Parse() type = %v, want %v", gotType, tt.wantType)
+			}
+		})
+	}
+}
+
+// pulseRecorder is the sender which records the pulses
+type pulseRecorder []Pulse
+
+func (r *pulseRecorder) Send(p Pulse) { *r = append(*r, p) }
+
+func TestGate_RegInput(t *testing.T) {
+	var sent pulseRecorder
+
+	g := NewAndGate("and", []string{"out"})
+	g.RegInput("a")
+	g.RegInput("b")
+
+	g.Process(Pulse{From: "a", To: "and", Val: HightPulse}, &sent)
+	g.RegInput("a") // repeated edge
+	g.Process(Pulse{From: "b", To: "and", Val: HightPulse}, &sent)
+	g.Process(Pulse{From: "a", To: "and", Val: LowPulse}, &sent)
+
+	if len(g.inputs) != 2 {
+		t.Errorf("inputs = %v, want a and b", g.inputs)
+	}
+
+	want := []PulseType{LowPulse, HightPulse, LowPulse}
+	got := make([]PulseType, len(sent))
+	for i, p := range sent {
+		got[i] = p.Val
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pulses = %v, want %v", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"adventofcode-2023/lib/queue"
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -146,6 +147,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.IntVar(&stepCount, "steps", stepCount, "number of steps")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -55,9 +55,10 @@ func Test_run(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			savedSteps := stepCount
 			debugEnable = tt.debug
 			stepCount = tt.stepCount
-			defer func() { debugEnable = false }()
+			defer func() { debugEnable = false; stepCount = savedSteps }()
 			w := &bytes.Buffer{}
 			if err := run(tt.args.r, w); (err != nil) != tt.wantErr {
 				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
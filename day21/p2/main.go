@@ -15,6 +15,9 @@ import (
 
 var (
 	stepCount      = 26501365
+	startPoint     = Point{-1, -1} // the S position if not set
+	expandFactor   = 0             // if set, the step count is k*n+n/2
+	solutionMode   = "analytic"
 	_, debugEnable = os.LookupEnv("DEBUG")
 )
 
@@ -199,11 +202,11 @@ func checkPlan(plan [][]byte) error {
 	m := len(plan[0])
 
 	if n != m {
-		panic(fmt.Sprintf("plan must be square, got %dx%d", n, m))
+		return fmt.Errorf("plan must be square, got %dx%d", n, m)
 	}
 
 	if n%2 != 1 {
-		panic(fmt.Sprintf("plan size must be odd, got %dx%d", n, m))
+		return fmt.Errorf("plan size must be odd, got %dx%d", n, m)
 	}
 
 	for i := 0; i < n; i++ {
@@ -302,24 +305,375 @@ func solution2(plan [][]byte, k int) int {
 	return total
 }
 
+// checkStart returns an error if the start point is out of the plan or on a rock
+func checkStart(plan [][]byte, start Point) error {
+	n := len(plan)
+	m := len(plan[0])
+
+	if start.i < 0 || start.i >= n || start.j < 0 || start.j >= m {
+		return fmt.Errorf("start point %v is out of plan %dx%d", start, n, m)
+	}
+
+	if plan[start.i][start.j] == '#' {
+		return fmt.Errorf("start point %v is a rock", start)
+	}
+
+	return nil
+}
+
+const maxBruteForceCells = 1 << 27 // expanded plan size limit
+
+// bruteForce counts the cells reachable in exactly count steps from the start point
+// on the infinite garden by walking over the expanded plan
+func bruteForce(plan [][]byte, start Point, count int) (int, error) {
+	const op = "bruteForce"
+
+	if err := checkStart(plan, start); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n := len(plan)
+	m := len(plan[0])
+
+	if count < 0 {
+		return 0, fmt.Errorf("%s: step count must be non-negative, got %d", op, count)
+	}
+
+	k := count/min(n, m) + 1
+
+	// the expanded plan has (2k+1)^2 copies of the plan
+	if k > maxBruteForceCells || (2*k+1)*n > maxBruteForceCells/((2*k+1)*m) {
+		return 0, fmt.Errorf("%s: %d steps expand the plan %dx%d over %d cells", op, count, n, m, maxBruteForceCells)
+	}
+
+	plan2 := expandPlan(plan, k)
+
+	x, _ := doSteps(plan2, 0, Point{start.i + k*n, start.j + k*m}, count)
+	return x[count%2], nil
+}
+
+const (
+	maxSolution3Samples = 256  // samples limit to detect a quadratic growth
+	maxSolution3Radius  = 5000 // BFS radius limit, the BFS time grows as its square
+	stableSamples       = 3    // number of equal second differences to detect a quadratic growth
+)
+
+// solution3 counts the cells reachable in exactly count steps from the start point on
+// the infinite garden for any plan. It uses that the number of reachable cells
+// f(r+k*p) eventually grows quadratically in k along each residue class of k modulo
+// some stride c, where p is the tiling period (the frontier can move slower than one
+// tile per period, so its phase repeats only after c periods). The values f are
+// calculated by BFS over the tiled copies of the plan until the second differences
+// stabilize, then f(count) is extrapolated.
+func solution3(plan [][]byte, start Point, count int) (int, error) {
+	const op = "solution3"
+
+	if err := checkStart(plan, start); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if count < 0 {
+		return 0, fmt.Errorf("%s: step count must be non-negative, got %d", op, count)
+	}
+
+	n := len(plan)
+	m := len(plan[0])
+
+	// the period after which the frontier meets the same tiles at the same parity
+	period := n / gcd(n, m) * m
+	if period%2 != 0 {
+		period *= 2
+	}
+
+	r := count % period
+	target := (count - r) / period
+
+	var (
+		bfs *layers
+		f   []int
+	)
+
+	for samples := stableSamples + 2; samples <= maxSolution3Samples; samples *= 2 {
+		radius := min(r+(samples-1)*period, count)
+		if radius > maxSolution3Radius {
+			return 0, fmt.Errorf("%s: BFS radius %d exceeds the limit %d, the period %d is too large", op, radius, maxSolution3Radius, period)
+		}
+
+		if bfs == nil {
+			bfs = newLayers(plan, start)
+		}
+
+		if radius == count {
+			bfs.walk(count)
+			return bfs.reachable(), nil
+		}
+
+		for k := len(f); k < samples; k++ {
+			bfs.walk(r + k*period)
+			f = append(f, bfs.reachable())
+		}
+
+		if debugEnable {
+			log.Printf("%s: period=%d r=%d f=%v", op, period, r, f)
+		}
+
+		c, ok := quadraticStride(f)
+		if !ok {
+			continue
+		}
+
+		// the last sample of the target residue class
+		last := samples - 1 - (samples-1-target%c+c)%c
+
+		// f(K+x*c) = f(K) + x*d1 + x*(x+1)/2*d2, where K is the last sample
+		d1 := f[last] - f[last-c]
+		d2 := f[last] - 2*f[last-c] + f[last-2*c]
+		x := (target - last) / c
+
+		if debugEnable {
+			log.Printf("%s: stride=%d last=%d d1=%d d2=%d x=%d", op, c, last, d1, d2, x)
+		}
+
+		return f[last] + x*d1 + x*(x+1)/2*d2, nil
+	}
+
+	return 0, fmt.Errorf("%s: growth did not become quadratic after %d periods", op, maxSolution3Samples)
+}
+
+// quadraticStride returns the minimal stride c such that the last stableSamples*c
+// samples have the same second difference with the stride c
+func quadraticStride(f []int) (int, bool) {
+	for c := 1; (stableSamples+2)*c <= len(f); c++ {
+		k0 := len(f) - stableSamples*c
+		d2 := f[k0] - 2*f[k0-c] + f[k0-2*c]
+
+		stable := true
+		for k := k0 + 1; k < len(f); k++ {
+			if f[k]-2*f[k-c]+f[k-2*c] != d2 {
+				stable = false
+				break
+			}
+		}
+
+		if stable {
+			return c, true
+		}
+	}
+
+	return 0, false
+}
+
+// layers is BFS over the infinite garden by the distance layers. The grid is
+// bipartite, so the neighbours of a layer are in the previous and the next layers
+// only, and keeping two layers is enough to not visit a cell twice. A layer is kept
+// by the tiles (copies of the plan) it crosses, so its size is O(step)
+type layers struct {
+	plan      [][]byte
+	prev, cur map[Point]*tile
+	pool      []*tile // cleared tiles to reuse
+	step      int
+	total     [2]int // number of cells with the minimal distance not greater than step by parity
+}
+
+// tile is the cells of a layer in one copy of the plan
+type tile struct {
+	has   []bool
+	cells []int // indexes i*m+j
+}
+
+func (t *tile) add(k int) bool {
+	if t.has[k] {
+		return false
+	}
+	t.has[k] = true
+	t.cells = append(t.cells, k)
+	return true
+}
+
+func newLayers(plan [][]byte, start Point) *layers {
+	l := &layers{
+		plan:  plan,
+		prev:  map[Point]*tile{},
+		cur:   map[Point]*tile{},
+		total: [2]int{1, 0},
+	}
+	l.tile(l.cur, Point{0, 0}).add(start.i*len(plan[0]) + start.j)
+	return l
+}
+
+// tile returns the tile at the position p of the layer, it adds the tile if missing
+func (l *layers) tile(layer map[Point]*tile, p Point) *tile {
+	t, ok := layer[p]
+	if !ok {
+		if k := len(l.pool) - 1; k >= 0 {
+			t = l.pool[k]
+			l.pool = l.pool[:k]
+		} else {
+			t = &tile{has: make([]bool, len(l.plan)*len(l.plan[0]))}
+		}
+		layer[p] = t
+	}
+	return t
+}
+
+// next moves BFS to the next layer
+func (l *layers) next() {
+	n := len(l.plan)
+	m := len(l.plan[0])
+
+	next := make(map[Point]*tile, len(l.cur))
+	count := 0
+
+	for p, t := range l.cur {
+		prevTile := l.prev[p]
+		var nextTile *tile
+
+		for _, k := range t.cells {
+			for _, o := range [...]Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				q := Point{k/m + o.i, k%m + o.j}
+				qp := p
+
+				switch {
+				case q.i < 0:
+					q.i += n
+					qp.i--
+				case q.i >= n:
+					q.i -= n
+					qp.i++
+				case q.j < 0:
+					q.j += m
+					qp.j--
+				case q.j >= m:
+					q.j -= m
+					qp.j++
+				}
+
+				if l.plan[q.i][q.j] == '#' {
+					continue
+				}
+
+				qk := q.i*m + q.j
+
+				if qp == p {
+					if prevTile != nil && prevTile.has[qk] {
+						continue
+					}
+					if nextTile == nil {
+						nextTile = l.tile(next, p)
+					}
+					if nextTile.add(qk) {
+						count++
+					}
+					continue
+				}
+
+				if t, ok := l.prev[qp]; ok && t.has[qk] {
+					continue
+				}
+				if l.tile(next, qp).add(qk) {
+					count++
+				}
+			}
+		}
+	}
+
+	for _, t := range l.prev {
+		for _, k := range t.cells {
+			t.has[k] = false
+		}
+		t.cells = t.cells[:0]
+		l.pool = append(l.pool, t)
+	}
+
+	l.step++
+	l.total[l.step%2] += count
+	l.prev, l.cur = l.cur, next
+}
+
+// walk moves BFS to the layer count
+func (l *layers) walk(count int) {
+	for l.step < count {
+		l.next()
+	}
+}
+
+// reachable returns the number of cells reachable in exactly step steps. A cell is
+// reachable if its minimal distance is not greater than step and has the same parity
+func (l *layers) reachable() int {
+	return l.total[l.step%2]
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
 	plan, err := readPlan(br)
 	if err != nil {
 		return err
 	}
 
-	if err := checkPlan(plan); err != nil {
-		log.Fatal(fmt.Errorf("bad plan: %v", err))
+	n := len(plan)
+	m := len(plan[0])
+
+	start := startPoint
+	if start == (Point{-1, -1}) {
+		start = getStartPoint(plan)
+		if start.i == -1 {
+			return fmt.Errorf("start point not found")
+		}
 	}
 
-	n := len(plan)
-	k := (stepCount - n/2) / n
+	if err := checkStart(plan, start); err != nil {
+		return err
+	}
+
+	count := stepCount
+	if expandFactor > 0 {
+		count = expandFactor*n + n/2
+	}
+
+	if debugEnable {
+		log.Printf("plan %dx%d start=%v count=%d mode=%s", n, m, start, count, solutionMode)
+	}
+
+	var cellCount int
+
+	switch solutionMode {
+	case "brute":
+		cellCount, err = bruteForce(plan, start, count)
+		if err != nil {
+			return err
+		}
+
+	case "analytic":
+		k := (count - n/2) / n
+
+		// solution2 is faster, but it requires the clean plan with the start at the center
+		if checkPlan(plan) == nil && start == (Point{n / 2, n / 2}) && k >= 1 && k*n+n/2 == count {
+			cellCount = solution2(plan, k)
+			break
+		}
+
+		cellCount, err = solution3(plan, start, count)
+		if err != nil {
+			return err
+		}
 
-	if k*n+n/2 != stepCount {
-		log.Fatal(fmt.Errorf("bad stepCount=%d", stepCount))
+	default:
+		return fmt.Errorf("unknown mode: %s", solutionMode)
 	}
 
-	cellCount := solution2(plan, k)
 	fmt.Fprintln(bw, cellCount)
 	return nil
 }
@@ -339,6 +693,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 func main() {
 	_ = debugEnable
 
+	flag.IntVar(&stepCount, "steps", stepCount, "number of steps")
+	flag.Var(&startPoint, "start", "start point `(i,j)`, (-1,-1) means the S position")
+	flag.IntVar(&expandFactor, "k", expandFactor, "expansion factor, if set the number of steps is k*n+n/2")
+	flag.StringVar(&solutionMode, "mode", solutionMode, "solution mode: brute|analytic")
 	flag.Parse()
 
 	if err := run(os.Stdin, os.Stdout); err != nil {
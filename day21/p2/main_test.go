@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"math/rand"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -390,3 +394,302 @@ func Test_solution2(t *testing.T) {
 	}
 }
 
+func Test_solution3(t *testing.T) {
+	bigPlan := readPlanFile("../adventofcode.com_2023_day_21_input.txt")
+	examplePlan := [][]byte{
+		[]byte("..........."),
+		[]byte(".....###.#."),
+		[]byte(".###.##..#."),
+		[]byte("..#.#...#.."),
+		[]byte("....#.#...."),
+		[]byte(".##..S####."),
+		[]byte(".##..#...#."),
+		[]byte(".......##.."),
+		[]byte(".##.#.####."),
+		[]byte(".##..##.##."),
+		[]byte("..........."),
+	}
+
+	type args struct {
+		plan  [][]byte
+		start Point
+		count int
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    int
+		wantErr bool
+	}{
+		{"example 6", args{examplePlan, Point{5, 5}, 6}, 16, false},
+		{"example 10", args{examplePlan, Point{5, 5}, 10}, 50, false},
+		{"example 50", args{examplePlan, Point{5, 5}, 50}, 1594, false},
+		{"example 100", args{examplePlan, Point{5, 5}, 100}, 6536, false},
+		{"example 500", args{examplePlan, Point{5, 5}, 500}, 167004, false},
+		{"example 1000", args{examplePlan, Point{5, 5}, 1000}, 668697, false},
+		{"example 5000", args{examplePlan, Point{5, 5}, 5000}, 16733044, false},
+		{"bigPlan 26501365", args{bigPlan, Point{65, 65}, 26501365}, 621289922886149, false},
+		{"rock", args{examplePlan, Point{1, 5}, 10}, 0, true},
+		{"out of plan", args{examplePlan, Point{11, 0}, 10}, 0, true},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := solution3(tt.args.plan, tt.args.start, tt.args.count)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("solution3() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("solution3() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_solution3_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(21))
+
+	for c := 0; c < 30; c++ {
+		n := 2 + rnd.Intn(6)
+		m := 2 + rnd.Intn(6)
+
+		plan, start := randomPlan(rnd, n, m)
+
+		for _, count := range []int{0, 1, rnd.Intn(20), 50 + rnd.Intn(100), 200 + rnd.Intn(200), 600 + rnd.Intn(400)} {
+			want, err := bruteForce(plan, start, count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := solution3(plan, start, count)
+			if err != nil {
+				t.Errorf("solution3(%q, %v, %d) error = %v", plan, start, count, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("solution3(%q, %v, %d) = %v, want %v", plan, start, count, got, want)
+			}
+		}
+	}
+}
+
+func randomPlan(rnd *rand.Rand, n, m int) ([][]byte, Point) {
+	plan := makeMatrix[byte](n, m)
+	for i := range plan {
+		for j := range plan[i] {
+			plan[i][j] = '.'
+			if rnd.Intn(4) == 0 {
+				plan[i][j] = '#'
+			}
+		}
+	}
+
+	start := Point{rnd.Intn(n), rnd.Intn(m)}
+	plan[start.i][start.j] = 'S'
+
+	return plan, start
+}
+
+func Test_solution3_nonSquare(t *testing.T) {
+	rnd := rand.New(rand.NewSource(22))
+
+	// lcm(9,8) = 72, the samples go up to thousands of steps
+	plan, start := randomPlan(rnd, 9, 8)
+	for _, count := range []int{1500, 1501} {
+		want, err := bruteForce(plan, start, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := solution3(plan, start, count)
+		if err != nil {
+			t.Fatalf("solution3(%q, %v, %d) error = %v", plan, start, count, err)
+		}
+		if got != want {
+			t.Errorf("solution3(%q, %v, %d) = %v, want %v", plan, start, count, got, want)
+		}
+	}
+
+	// lcm(41,40) = 1640, the BFS radius is over the limit
+	plan, start = randomPlan(rnd, 41, 40)
+	if _, err := solution3(plan, start, 26501365); err == nil {
+		t.Errorf("solution3(41x40, %v, 26501365) error = nil, want the radius limit error", start)
+	}
+}
+
+func Test_bruteForce_rock(t *testing.T) {
+	plan := [][]byte{
+		[]byte(".#."),
+		[]byte(".S."),
+		[]byte("..."),
+	}
+
+	if _, err := bruteForce(plan, Point{0, 1}, 10); err == nil {
+		t.Error("bruteForce() error = nil, want the rock error")
+	}
+	if _, err := solution3(plan, Point{0, 1}, 10); err == nil {
+		t.Error("solution3() error = nil, want the rock error")
+	}
+}
+
+func Test_run(t *testing.T) {
+	const example = `...........
+	.....###.#.
+	.###.##..#.
+	..#.#...#..
+	....#.#....
+	.##..S####.
+	.##..#...#.
+	.......##..
+	.##.#.####.
+	.##..##.##.
+	...........`
+
+	type args struct {
+		r io.Reader
+	}
+	tests := []struct {
+		name    string
+		steps   int
+		start   Point
+		k       int
+		mode    string
+		args    args
+		wantW   string
+		wantErr bool
+		debug   bool
+	}{
+		{
+			"analytic 100",
+			100,
+			Point{-1, -1},
+			0,
+			"analytic",
+			args{strings.NewReader(example)},
+			`6536`,
+			false,
+			true,
+		},
+		{
+			"brute 100",
+			100,
+			Point{-1, -1},
+			0,
+			"brute",
+			args{strings.NewReader(example)},
+			`6536`,
+			false,
+			true,
+		},
+		{
+			"analytic k=4",
+			0,
+			Point{-1, -1},
+			4,
+			"analytic",
+			args{strings.NewReader(example)},
+			`1528`,
+			false,
+			true,
+		},
+		{
+			"brute k=4",
+			0,
+			Point{-1, -1},
+			4,
+			"brute",
+			args{strings.NewReader(example)},
+			`1528`,
+			false,
+			true,
+		},
+		{
+			"start",
+			1,
+			Point{0, 0},
+			0,
+			"analytic",
+			args{strings.NewReader(example)},
+			`4`,
+			false,
+			true,
+		},
+		{
+			"start out of plan",
+			1,
+			Point{0, 11},
+			0,
+			"analytic",
+			args{strings.NewReader(example)},
+			``,
+			true,
+			true,
+		},
+		{
+			"brute start on rock",
+			1,
+			Point{1, 5},
+			0,
+			"brute",
+			args{strings.NewReader(example)},
+			``,
+			true,
+			true,
+		},
+		{
+			"analytic start on rock",
+			1,
+			Point{1, 5},
+			0,
+			"analytic",
+			args{strings.NewReader(example)},
+			``,
+			true,
+			true,
+		},
+		{
+			"brute too many steps",
+			26501365,
+			Point{-1, -1},
+			0,
+			"brute",
+			args{strings.NewReader(example)},
+			``,
+			true,
+			true,
+		},
+		{
+			"bad mode",
+			1,
+			Point{-1, -1},
+			0,
+			"magic",
+			args{strings.NewReader(example)},
+			``,
+			true,
+			true,
+		},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedSteps, savedStart, savedK, savedMode := stepCount, startPoint, expandFactor, solutionMode
+			debugEnable = tt.debug
+			stepCount = tt.steps
+			startPoint = tt.start
+			expandFactor = tt.k
+			solutionMode = tt.mode
+			defer func() {
+				debugEnable = false
+				stepCount, startPoint, expandFactor, solutionMode = savedSteps, savedStart, savedK, savedMode
+			}()
+			w := &bytes.Buffer{}
+			if err := run(tt.args.r, w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type Point3 struct {
+	x, y, z int
+}
+
+// Brick is the cuboid between the corners a and b inclusive, a <= b by each coordinate
+type Brick struct {
+	a, b Point3
+}
+
+func parsePoint3(s []byte) (Point3, error) {
+	parts := bytes.Split(s, []byte(","))
+	if len(parts) != 3 {
+		return Point3{}, fmt.Errorf("invalid point '%s'", s)
+	}
+
+	var v [3]int
+	for k, part := range parts {
+		var err error
+		v[k], err = strconv.Atoi(string(part))
+		if err != nil {
+			return Point3{}, err
+		}
+	}
+
+	return Point3{v[0], v[1], v[2]}, nil
+}
+
+// parseBrick parses `x,y,z~x,y,z`
+func parseBrick(line []byte) (Brick, error) {
+	s1, s2, ok := bytes.Cut(line, []byte("~"))
+	if !ok {
+		return Brick{}, fmt.Errorf("'~' not found")
+	}
+
+	a, err := parsePoint3(s1)
+	if err != nil {
+		return Brick{}, err
+	}
+	b, err := parsePoint3(s2)
+	if err != nil {
+		return Brick{}, err
+	}
+
+	br := Brick{
+		a: Point3{min(a.x, b.x), min(a.y, b.y), min(a.z, b.z)},
+		b: Point3{max(a.x, b.x), max(a.y, b.y), max(a.z, b.z)},
+	}
+	if br.a.x < 0 || br.a.y < 0 || br.a.z < 1 {
+		return Brick{}, fmt.Errorf("brick %v is below the ground or out of the area", br)
+	}
+
+	return br, nil
+}
+
+func readBricks(br *bufio.Reader) ([]Brick, error) {
+	var bricks []Brick
+
+	for lineNo := 1; ; lineNo++ {
+		line, isPrefix, err := br.ReadLine()
+		if isPrefix {
+			return nil, fmt.Errorf("%d: line too long", lineNo)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("%d: %w", lineNo, err)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		b, err := parseBrick(line)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", lineNo, err)
+		}
+		bricks = append(bricks, b)
+	}
+
+	return bricks, nil
+}
+
+// settle drops the bricks down until they rest on the ground or other bricks. It
+// returns for each brick the bricks resting on it and the bricks it rests on
+func settle(bricks []Brick) (supports, supportedBy [][]int) {
+	sort.Slice(bricks, func(i, j int) bool {
+		return bricks[i].a.z < bricks[j].a.z
+	})
+
+	var maxX, maxY int
+	for _, b := range bricks {
+		maxX = max(maxX, b.b.x)
+		maxY = max(maxY, b.b.y)
+	}
+
+	// the height map: the top z and the brick id over each column
+	type top struct {
+		z, id int
+	}
+	heights := makeMatrix[top](maxX+1, maxY+1)
+	for _, row := range heights {
+		for y := range row {
+			row[y].id = -1
+		}
+	}
+
+	supports = make([][]int, len(bricks))
+	supportedBy = make([][]int, len(bricks))
+
+	for id := range bricks {
+		b := &bricks[id]
+
+		rest := 0
+		for x := b.a.x; x <= b.b.x; x++ {
+			for y := b.a.y; y <= b.b.y; y++ {
+				rest = max(rest, heights[x][y].z)
+			}
+		}
+
+		for x := b.a.x; x <= b.b.x; x++ {
+			for y := b.a.y; y <= b.b.y; y++ {
+				h := heights[x][y]
+				if h.id == -1 || h.z != rest || contains(supportedBy[id], h.id) {
+					continue
+				}
+				supportedBy[id] = append(supportedBy[id], h.id)
+				supports[h.id] = append(supports[h.id], id)
+			}
+		}
+
+		fall := b.a.z - rest - 1
+		b.a.z -= fall
+		b.b.z -= fall
+
+		for x := b.a.x; x <= b.b.x; x++ {
+			for y := b.a.y; y <= b.b.y; y++ {
+				heights[x][y] = top{b.b.z, id}
+			}
+		}
+	}
+
+	return supports, supportedBy
+}
+
+func contains(a []int, v int) bool {
+	for _, x := range a {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func makeMatrix[T any](n, m int) [][]T {
+	buf := make([]T, n*m)
+	matrix := make([][]T, n)
+	for i, j := 0, 0; i < n; i, j = i+1, j+m {
+		matrix[i] = buf[j : j+m]
+	}
+	return matrix
+}
+
+func max(a, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// safeCount counts the bricks which can be removed without any other brick falling
+func safeCount(supports, supportedBy [][]int) int {
+	count := 0
+	for _, above := range supports {
+		safe := true
+		for _, id := range above {
+			if len(supportedBy[id]) < 2 {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			count++
+		}
+	}
+	return count
+}
+
+func _run(br *bufio.Reader, bw *bufio.Writer) error {
+	bricks, err := readBricks(br)
+	if err != nil {
+		return err
+	}
+
+	supports, supportedBy := settle(bricks)
+	if debugEnable {
+		for id, b := range bricks {
+			log.Printf("%d: %v supports %v, supported by %v", id, b, supports[id], supportedBy[id])
+		}
+	}
+
+	fmt.Fprintln(bw, safeCount(supports, supportedBy))
+	return nil
+}
+
+func run(r io.Reader, w io.Writer) (err error) {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	defer func() {
+		if flushErr := bw.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}()
+
+	return _run(br, bw)
+}
+
+func main() {
+	_ = debugEnable
+	if err := run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var _, debugEnable = os.LookupEnv("DEBUG")
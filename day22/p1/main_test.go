@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	type args struct {
+		r io.Reader
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantW   string
+		wantErr bool
+		debug   bool
+	}{
+		{
+			"1",
+			args{strings.NewReader(`1,0,1~1,2,1
+0,0,2~2,0,2
+0,2,3~2,2,3
+0,0,4~0,2,4
+2,0,5~2,2,5
+0,1,6~2,1,6
+1,1,8~1,1,9`)},
+			`5`,
+			false,
+			true,
+		},
+		{
+			"cube column",
+			args{strings.NewReader(`1,1,5~1,1,5
+1,1,2~1,1,2
+1,1,9~1,1,9`)},
+			`1`,
+			false,
+			true,
+		},
+		{
+			"cubes side by side",
+			args{strings.NewReader(`0,0,3~0,0,3
+1,0,1~1,0,1`)},
+			`2`,
+			false,
+			false,
+		},
+		{
+			"bridge on cubes",
+			args{strings.NewReader(`0,0,1~0,0,1
+2,0,1~2,0,1
+2,0,4~0,0,4`)},
+			`3`,
+			false,
+			false,
+		},
+		{
+			"single cube",
+			args{strings.NewReader(`3,3,7~3,3,7`)},
+			`1`,
+			false,
+			false,
+		},
+		{
+			"no tilde",
+			args{strings.NewReader(`1,0,1,1,2,1`)},
+			``,
+			true,
+			false,
+		},
+		{
+			"underground",
+			args{strings.NewReader(`1,0,0~1,2,0`)},
+			``,
+			true,
+			false,
+		},
+		{
+			"bad number",
+			args{strings.NewReader(`1,0,x~1,2,1`)},
+			``,
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debugEnable = tt.debug
+			defer func() { debugEnable = false }()
+			w := &bytes.Buffer{}
+			if err := run(tt.args.r, w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"unicode"
 	"unsafe"
 )
@@ -18,14 +22,59 @@ type Number struct {
 	val    int
 }
 
-type Gear struct {
-	n int
-	val int
+type Symbol struct {
+	i, j int
+	c    byte
 }
 
-type SimbolSet map[[2]int]Gear
+// Index is the spatial index of the schematic: the cells refer to the numbers and the
+// symbols placed in them
+type Index struct {
+	Numbers []Number
+	Symbols []Symbol
 
-func parseLine(i int, s []byte, nums *[]Number, simbols SimbolSet) error {
+	numberAt map[[2]int]int // every digit cell
+	symbolAt map[[2]int]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		numberAt: make(map[[2]int]int),
+		symbolAt: make(map[[2]int]int),
+	}
+}
+
+// symbolSet is the symbol characters, any character except digits, '.', spaces and
+// control characters if empty
+var symbolSet = ""
+
+func isSymbol(c byte) bool {
+	if symbolSet == "" {
+		r := rune(c)
+		return c != '.' && !unicode.IsDigit(r) && !unicode.IsSpace(r) && !unicode.IsControl(r)
+	}
+	return strings.IndexByte(symbolSet, c) != -1
+}
+
+// checkGearSymbols returns an error if a gear symbol is not a symbol
+func checkGearSymbols() error {
+	for k := 0; k < len(gearSymbols); k++ {
+		if c := gearSymbols[k]; !isSymbol(c) {
+			return fmt.Errorf("gear symbol '%c' is not in the symbols %q", c, symbolSet)
+		}
+	}
+	return nil
+}
+
+func (x *Index) addNumber(num Number) {
+	k := len(x.Numbers)
+	x.Numbers = append(x.Numbers, num)
+	for j := num.j0; j < num.j1; j++ {
+		x.numberAt[[2]int{num.i, j}] = k
+	}
+}
+
+func (x *Index) parseLine(i int, s []byte) error {
 	var numVal int
 	var numLen int
 
@@ -38,97 +87,178 @@ func parseLine(i int, s []byte, nums *[]Number, simbols SimbolSet) error {
 		}
 
 		if numLen != 0 {
-			*nums = append(*nums, Number{i: i, j0: j - numLen, j1: j, val: numVal})
+			x.addNumber(Number{i: i, j0: j - numLen, j1: j, val: numVal})
 			numVal = 0
 			numLen = 0
 		}
 
-		if c == '*' {
-			simbols[[2]int{i, j}] = Gear{val:1}
+		if isSymbol(c) {
+			x.symbolAt[[2]int{i, j}] = len(x.Symbols)
+			x.Symbols = append(x.Symbols, Symbol{i, j, c})
 		}
 	}
 
 	if numLen != 0 {
-		*nums = append(*nums, Number{i: i, j0: len(s) - numLen, j1: len(s), val: numVal})
-		numVal = 0
-		numLen = 0
+		x.addNumber(Number{i: i, j0: len(s) - numLen, j1: len(s), val: numVal})
 	}
 
 	return nil
 }
 
-func checkNumber(num *Number, simbols SimbolSet) int {
-	for i, j := num.i-1, num.j0-1; j <= num.j1; j++ {
-		p := [2]int{i, j}
-		if g, ok := simbols[p]; ok {
-			g.n++
-			g.val *= num.val
-			simbols[p] = g
-		}
+// border calls f for the cells around the rectangle of the row i and columns [j0, j1)
+func border(i, j0, j1 int, f func(p [2]int)) {
+	for j := j0 - 1; j <= j1; j++ {
+		f([2]int{i - 1, j})
+		f([2]int{i + 1, j})
 	}
+	f([2]int{i, j0 - 1})
+	f([2]int{i, j1})
+}
 
-	for i, j := num.i+1, num.j0-1; j <= num.j1; j++ {
-		p := [2]int{i, j}
-		if g, ok := simbols[p]; ok {
-			g.n++
-			g.val *= num.val
-			simbols[p] = g
+// NumbersTouching returns the indexes of the numbers adjacent to the symbol s
+func (x *Index) NumbersTouching(s int) []int {
+	sym := x.Symbols[s]
+	res := []int{}
+	border(sym.i, sym.j, sym.j+1, func(p [2]int) {
+		k, ok := x.numberAt[p]
+		if !ok {
+			return
 		}
-	}
+		// a number may touch the symbol by several digits
+		for _, v := range res {
+			if v == k {
+				return
+			}
+		}
+		res = append(res, k)
+	})
+	sort.Ints(res)
+	return res
+}
 
-	{
-		p := [2]int{num.i, num.j0 - 1}
-		if g, ok := simbols[p]; ok {
-			g.n++
-			g.val *= num.val
-			simbols[p] = g
+// SymbolsTouching returns the indexes of the symbols adjacent to the number n
+func (x *Index) SymbolsTouching(n int) []int {
+	num := x.Numbers[n]
+	res := []int{}
+	border(num.i, num.j0, num.j1, func(p [2]int) {
+		if k, ok := x.symbolAt[p]; ok {
+			res = append(res, k)
 		}
+	})
+	sort.Ints(res)
+	return res
+}
+
+var (
+	gearSymbols = "*"
+	gearCount   = 2
+)
+
+// GearRatio returns the product of the numbers adjacent to the symbol if the symbol is
+// a gear: one of gearSymbols with exactly gearCount adjacent numbers
+func (x *Index) GearRatio(s int) (int, bool) {
+	if strings.IndexByte(gearSymbols, x.Symbols[s].c) == -1 {
+		return 0, false
+	}
+
+	nums := x.NumbersTouching(s)
+	if len(nums) != gearCount {
+		return 0, false
+	}
+
+	ratio := 1
+	for _, k := range nums {
+		ratio *= x.Numbers[k].val
+	}
+	return ratio, true
+}
+
+type numberJSON struct {
+	Value   int   `json:"value"`
+	Row     int   `json:"row"`
+	Col     int   `json:"col"`
+	Len     int   `json:"len"`
+	Part    bool  `json:"part"`
+	Symbols []int `json:"symbols"`
+}
+
+type symbolJSON struct {
+	Symbol  string `json:"symbol"`
+	Row     int    `json:"row"`
+	Col     int    `json:"col"`
+	Numbers []int  `json:"numbers"`
+	Gear    bool   `json:"gear"`
+	Ratio   int    `json:"ratio,omitempty"`
+}
+
+type schematicJSON struct {
+	Numbers      []numberJSON `json:"numbers"`
+	Symbols      []symbolJSON `json:"symbols"`
+	PartSum      int          `json:"partSum"`
+	GearRatioSum int          `json:"gearRatioSum"`
+}
+
+// export returns the numbers and the symbols with the 0-based coordinates and the
+// indexes of the adjacent items
+func (x *Index) export() schematicJSON {
+	res := schematicJSON{
+		Numbers: make([]numberJSON, len(x.Numbers)),
+		Symbols: make([]symbolJSON, len(x.Symbols)),
 	}
 
-	{
-		p := [2]int{num.i, num.j1}
-		if g, ok := simbols[p]; ok {
-			g.n++
-			g.val *= num.val
-			simbols[p] = g
+	for k, num := range x.Numbers {
+		syms := x.SymbolsTouching(k)
+		res.Numbers[k] = numberJSON{num.val, num.i, num.j0, num.j1 - num.j0, len(syms) != 0, syms}
+		if len(syms) != 0 {
+			res.PartSum += num.val
 		}
 	}
 
-	return 0
+	for k, sym := range x.Symbols {
+		ratio, gear := x.GearRatio(k)
+		res.Symbols[k] = symbolJSON{string(sym.c), sym.i, sym.j, x.NumbersTouching(k), gear, ratio}
+		res.GearRatioSum += ratio
+	}
+
+	return res
 }
 
+var exportJSON = false
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	var lineN int
-	nums := []Number{}
-	simbols := SimbolSet{}
+	if err := checkGearSymbols(); err != nil {
+		return err
+	}
+
+	x := NewIndex()
 
-	for {
-		lineN++
+	for lineN := 0; ; lineN++ {
 		s, isPrefix, err := br.ReadLine()
 		if isPrefix {
-			return fmt.Errorf("%d: line too long", lineN)
+			return fmt.Errorf("%d: line too long", lineN+1)
 		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return fmt.Errorf("%d: %w", lineN, err)
+			return fmt.Errorf("%d: %w", lineN+1, err)
 		}
 
-		if err := parseLine(lineN, s, &nums, simbols); err != nil {
-			return fmt.Errorf("%d: can't parse line: %w", lineN, err)
+		if err := x.parseLine(lineN, s); err != nil {
+			return fmt.Errorf("%d: can't parse line: %w", lineN+1, err)
 		}
 	}
 
-	for i := range nums {
-		checkNumber(&nums[i], simbols)
+	if exportJSON {
+		enc := json.NewEncoder(bw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(x.export())
 	}
 
 	var sum int
-	for _, g := range simbols {
-		if g.n == 2 {
-			sum += g.val
-		}
+	for k := range x.Symbols {
+		ratio, _ := x.GearRatio(k)
+		sum += ratio
 	}
 
 	fmt.Fprintln(bw, sum)
@@ -150,6 +280,13 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&symbolSet, "symbols", symbolSet, "symbol characters (default any except digits, '.' and spaces)")
+	flag.StringVar(&gearSymbols, "gear", gearSymbols, "gear symbol characters")
+	flag.IntVar(&gearCount, "gear-count", gearCount, "number of adjacent numbers a gear has")
+	flag.BoolVar(&exportJSON, "json", exportJSON, "export the numbers and the symbols as JSON")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
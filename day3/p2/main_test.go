@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -65,3 +67,129 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_rules(t *testing.T) {
+	const grid = `12.
+.*3
+4.#`
+
+	tests := []struct {
+		name      string
+		symbols   string
+		gear      string
+		gearCount int
+		wantW     string
+		wantErr   bool
+	}{
+		{"default", "", "*", 2, `0`, false},
+		{"three numbers", "", "*", 3, `144`, false},
+		{"any gear", "", "*#", 1, `3`, false},
+		{"only hash", "#", "#", 1, `3`, false},
+		{"no symbols", "$", "$", 1, `0`, false},
+		{"gear not a symbol", "#", "*#", 1, ``, true},
+		{"dot gear", "", ".", 1, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbolSet = tt.symbols
+			gearSymbols = tt.gear
+			gearCount = tt.gearCount
+			defer func() { symbolSet = ""; gearSymbols = "*"; gearCount = 2 }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(grid), w); (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != tt.wantW {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func Test_run_json(t *testing.T) {
+	exportJSON = true
+	defer func() { exportJSON = false }()
+
+	w := &bytes.Buffer{}
+	err := run(strings.NewReader(`467..114..
+...*......
+..35..633.
+......#...
+617*......
+.....+.58.
+..592.....
+......755.
+...$.*....
+.664.598..`), w)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	var got schematicJSON
+	if err := json.Unmarshal(w.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if got.PartSum != 4361 || got.GearRatioSum != 467835 {
+		t.Errorf("sums = %d, %d, want 4361, 467835", got.PartSum, got.GearRatioSum)
+	}
+
+	if len(got.Numbers) != 10 || len(got.Symbols) != 6 {
+		t.Fatalf("got %d numbers and %d symbols, want 10 and 6", len(got.Numbers), len(got.Symbols))
+	}
+
+	// 114 at the row 0 is not a part
+	wantNum := numberJSON{Value: 114, Row: 0, Col: 5, Len: 3, Part: false, Symbols: []int{}}
+	if n := got.Numbers[1]; !reflect.DeepEqual(n, wantNum) {
+		t.Errorf("Numbers[1] = %+v, want %+v", n, wantNum)
+	}
+
+	// the first gear touches 467 and 35
+	wantSym := symbolJSON{Symbol: "*", Row: 1, Col: 3, Numbers: []int{0, 2}, Gear: true, Ratio: 16345}
+	if s := got.Symbols[0]; !reflect.DeepEqual(s, wantSym) {
+		t.Errorf("Symbols[0] = %+v, want %+v", s, wantSym)
+	}
+}
+
+func TestIndex_Touching(t *testing.T) {
+	x := NewIndex()
+	for i, line := range []string{"12.", ".*3", "4.#"} {
+		if err := x.parseLine(i, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := x.NumbersTouching(0), []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NumbersTouching(0) = %v, want %v", got, want)
+	}
+	if got, want := x.NumbersTouching(1), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NumbersTouching(1) = %v, want %v", got, want)
+	}
+	if got, want := x.SymbolsTouching(1), []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SymbolsTouching(1) = %v, want %v", got, want)
+	}
+	if got, want := x.SymbolsTouching(2), []int{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SymbolsTouching(2) = %v, want %v", got, want)
+	}
+}
+
+func Test_run_crlf(t *testing.T) {
+	exportJSON = true
+	defer func() { exportJSON = false }()
+
+	w := &bytes.Buffer{}
+	if err := run(strings.NewReader("12.\r\n.*3\r\n45 \r\n"), w); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	var got schematicJSON
+	if err := json.Unmarshal(w.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	// '\r' and ' ' are not symbols, 45 touches only '*'
+	if len(got.Symbols) != 1 || got.PartSum != 60 {
+		t.Errorf("got %d symbols and partSum %d, want 1 and 60", len(got.Symbols), got.PartSum)
+	}
+}
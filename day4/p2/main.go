@@ -3,74 +3,208 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"unsafe"
 )
 
-func parseLine(s []byte) (map[int]struct{}, []int, error) {
+// Card is the result of a scratchcard
+type Card struct {
+	ID      int `json:"id"`
+	Matches int `json:"matches"`
+	Copies  int `json:"copies"`
+	Points  int `json:"points"`
+}
+
+// parseInts appends the numbers separated by spaces to dst
+func parseInts(dst []int, b []byte) ([]int, error) {
+	for _, w := range bytes.Fields(b) {
+		v, err := strconv.Atoi(unsafeString(w))
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+// Engine processes the scratchcards one by one keeping only the copies won for the
+// next cards, so the memory depends on the numbers per card, not the number of cards
+type Engine struct {
+	winning []int
+	numbers []int
+	pending []int // pending[k] is the copies won for the k-th card after the current one
+
+	Cards  int // total number of cards including copies (part 2)
+	Points int // total points of the original cards (part 1)
+}
+
+// Next processes the line `Card N: winning | numbers`
+func (e *Engine) Next(line []byte) (Card, error) {
 	var (
-		winning = map[int]struct{}{}
-		numbers = []int{}
+		card Card
+		err  error
 	)
 
-	scan := NewScanner(bytes.NewReader(s))
+	head, body, ok := bytes.Cut(line, []byte(":"))
+	if !ok {
+		return card, fmt.Errorf("':' not found")
+	}
+	if !bytes.HasPrefix(head, []byte("Card")) {
+		return card, fmt.Errorf("invalid card header %q", head)
+	}
+	card.ID, err = strconv.Atoi(unsafeString(bytes.TrimSpace(head[len("Card"):])))
+	if err != nil {
+		return card, fmt.Errorf("invalid card id: %w", err)
+	}
 
-	// skip: Game #:
-	scan.Scan()
-	scan.Scan()
-	if err := scan.Err(); err != nil {
-		return nil, nil, err
+	win, nums, ok := bytes.Cut(body, []byte("|"))
+	if !ok {
+		return card, fmt.Errorf("'|' not found")
 	}
 
-	for {
-		v, err := scan.Int()
-		if err != nil {
-			if scan.Text()[0] == '|' {
-				break
-			}
-			return nil, nil, err
-		}
-		winning[v] = struct{}{}
+	e.winning, err = parseInts(e.winning[:0], win)
+	if err != nil {
+		return card, err
+	}
+	e.numbers, err = parseInts(e.numbers[:0], nums)
+	if err != nil {
+		return card, err
 	}
 
-	for {
-		v, err := scan.Int()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, nil, err
+	sort.Ints(e.winning)
+	for _, v := range e.numbers {
+		k := sort.SearchInts(e.winning, v)
+		if k < len(e.winning) && e.winning[k] == v {
+			card.Matches++
 		}
-		numbers = append(numbers, v)
+	}
+	if card.Matches > 0 {
+		card.Points = 1 << (card.Matches - 1)
+	}
+
+	card.Copies = 1
+	if len(e.pending) > 0 {
+		card.Copies += e.pending[0]
+		e.pending = e.pending[1:]
 	}
 
-	return winning, numbers, nil
+	for len(e.pending) < card.Matches {
+		e.pending = append(e.pending, 0)
+	}
+	for k := 0; k < card.Matches; k++ {
+		e.pending[k] += card.Copies
+	}
+
+	e.Cards += card.Copies
+	e.Points += card.Points
+
+	return card, nil
+}
+
+var outputFormat = ""
+
+// Writer writes the cards in the output format as they come
+type Writer interface {
+	WriteCard(card Card) error
+	Close(e *Engine) error
 }
 
-func checkNumbers(winning map[int]struct{}, numbers []int) int {
-	sum := 0
+// totalWriter writes only the total number of cards
+type totalWriter struct {
+	w io.Writer
+}
 
-	for _, v := range numbers {
-		if _, ok := winning[v]; ok {
-			sum++
+func (tw totalWriter) WriteCard(Card) error {
+	return nil
+}
+
+func (tw totalWriter) Close(e *Engine) error {
+	_, err := fmt.Fprintln(tw.w, e.Cards)
+	return err
+}
+
+type tableWriter struct {
+	w io.Writer
+}
+
+func (tw tableWriter) WriteCard(card Card) error {
+	_, err := fmt.Fprintf(tw.w, "%6d %7d %6d %6d\n", card.ID, card.Matches, card.Copies, card.Points)
+	return err
+}
+
+func (tw tableWriter) Close(e *Engine) error {
+	_, err := fmt.Fprintf(tw.w, "points %d\ncards %d\n", e.Points, e.Cards)
+	return err
+}
+
+// jsonWriter writes {"cards": [...], "points": N, "total": M} streaming the cards
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (jw *jsonWriter) WriteCard(card Card) error {
+	buf, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if jw.count == 0 {
+		sep = "{\"cards\":[\n"
+	}
+	jw.count++
+
+	_, err = fmt.Fprintf(jw.w, "%s%s", sep, buf)
+	return err
+}
+
+func (jw *jsonWriter) Close(e *Engine) error {
+	if jw.count == 0 {
+		if _, err := io.WriteString(jw.w, "{\"cards\":["); err != nil {
+			return err
 		}
 	}
+	_, err := fmt.Fprintf(jw.w, "\n],\"points\":%d,\"total\":%d}\n", e.Points, e.Cards)
+	return err
+}
 
-	return sum
+func newWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case "":
+		return totalWriter{w}, nil
+	case "table":
+		if _, err := fmt.Fprintf(w, "%6s %7s %6s %6s\n", "card", "matches", "copies", "points"); err != nil {
+			return nil, err
+		}
+		return tableWriter{w}, nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
 }
 
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	var counts []int
+	cw, err := newWriter(bw, outputFormat)
+	if err != nil {
+		return err
+	}
+
+	var e Engine
 
-	for i := 0; ;i++{
+	for i := 1; ; i++ {
 		s, isPrefix, err := br.ReadLine()
 		if isPrefix {
-			return fmt.Errorf("%d: line too long", i+1)
+			return fmt.Errorf("%d: line too long", i)
 		}
 		if err != nil {
 			if err == io.EOF {
@@ -79,41 +213,25 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 			return fmt.Errorf("%d: %w", i, err)
 		}
 
-		if len(counts) == i {
-			counts = append(counts, 0)
+		if len(bytes.TrimSpace(s)) == 0 {
+			continue
 		}
 
-		counts[i]++
-
-		winning, numbers, err := parseLine(s)
+		card, err := e.Next(s)
 		if err != nil {
-			return fmt.Errorf("%d: can't parse line: %w", i+1, err)
+			return fmt.Errorf("%d: can't parse line: %w", i, err)
 		}
 
-
-		v := checkNumbers(winning, numbers)
-		n := i + 1 + v
-
-		for len(counts) < n {
-			counts = append(counts, 0)
+		if debugEnable {
+			log.Printf("%+v", card)
 		}
 
-		for j := i+1; j < n; j++ {
-			counts[j] += counts[i]
+		if err := cw.WriteCard(card); err != nil {
+			return err
 		}
 	}
 
-	if debugEnable {
-		log.Printf("%v", counts)
-	}
-
-	sum := 0
-	for _, v := range counts {
-		sum += v
-	}
-
-	fmt.Fprintln(bw, sum)
-	return nil
+	return cw.Close(&e)
 }
 
 func run(r io.Reader, w io.Writer) (err error) {
@@ -130,6 +248,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&outputFormat, "format", outputFormat, "print the per-card data: table or json")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -62,3 +65,102 @@ Card 6: 31 18 13 56 72 | 74 77 10 23 35 67 36 11
 		})
 	}
 }
+
+const example = `Card 1: 41 48 83 86 17 | 83 86  6 31 17  9 48 53
+Card 2: 13 32 20 16 61 | 61 30 68 82 17 32 24 19
+Card 3:  1 21 53 59 44 | 69 82 63 72 16 21 14  1
+Card 4: 41 92 73 84 69 | 59 84 76 51 58  5 54 83
+Card 5: 87 83 26 28 32 | 88 30 70 12 93 22 82 36
+Card 6: 31 18 13 56 72 | 74 77 10 23 35 67 36 11`
+
+func Test_run_format(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{
+			"table",
+			"table",
+			example,
+			`  card matches copies points
+     1       4      1      8
+     2       2      2      2
+     3       2      4      2
+     4       1      8      1
+     5       0     14      0
+     6       0      1      0
+points 13
+cards 30`,
+			false,
+		},
+		{
+			"json",
+			"json",
+			example,
+			`{"cards":[
+{"id":1,"matches":4,"copies":1,"points":8},
+{"id":2,"matches":2,"copies":2,"points":2},
+{"id":3,"matches":2,"copies":4,"points":2},
+{"id":4,"matches":1,"copies":8,"points":1},
+{"id":5,"matches":0,"copies":14,"points":0},
+{"id":6,"matches":0,"copies":1,"points":0}
+],"points":13,"total":30}`,
+			false,
+		},
+		{"json empty", "json", ``, "{\"cards\":[\n],\"points\":0,\"total\":0}", false},
+		{"unknown format", "xml", example, ``, true},
+		{"no bar", "", "Card 1: 41 48", ``, true},
+		{"no colon", "", "Card 1 41 | 48", ``, true},
+		{"bad id", "", "Card x: 41 | 48", ``, true},
+		{"bad number", "", "Card 1: 41 | 4x", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputFormat = tt.format
+			defer func() { outputFormat = "" }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+			if tt.format == "json" && !json.Valid(w.Bytes()) {
+				t.Errorf("run() = %v, invalid json", w.String())
+			}
+		})
+	}
+}
+
+func Test_run_stream(t *testing.T) {
+	// each card wins the next one, so the copies grow by one
+	const n = 100000
+
+	pr, pw := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(pw)
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(bw, "Card %d: 1 2 3 | 3 4 5\n", i)
+		}
+		bw.Flush()
+		pw.Close()
+	}()
+
+	w := &bytes.Buffer{}
+	if err := run(pr, w); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	// 1 + 2 + ... + n
+	if got, want := strings.TrimSpace(w.String()), fmt.Sprint(n*(n+1)/2); got != want {
+		t.Errorf("run() = %v, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -26,44 +28,103 @@ func (m Map) Sort() {
 	})
 }
 
-func (m Map) Get(src int) (int, int) {
-	i := sort.Search(len(m), func(i int) bool {
-		return m[i].src > src
-	})
-	i--
+// Piece shifts the numbers [lo, hi) by off
+type Piece struct {
+	lo, hi int
+	off    int
+}
 
-	if i != -1 && src < m[i].src+m[i].len {
-		of := src - m[i].src
-		return m[i].dst + of, m[i].len - of
-	}
+// Mapping is a piecewise shift function. The pieces are sorted and cover [0, MaxInt)
+// without gaps
+type Mapping []Piece
 
-	if i+1 == len(m) {
-		return src, math.MaxInt - src // XXX
+// NewMapping returns the mapping of the almanac map, the numbers out of the map ranges
+// are mapped to themselves
+func NewMapping(m Map) (Mapping, error) {
+	m.Sort()
+
+	var f Mapping
+	lo := 0
+	for _, it := range m {
+		if it.src < lo {
+			return nil, fmt.Errorf("overlapped map ranges at %d", it.src)
+		}
+		if lo < it.src {
+			f = append(f, Piece{lo, it.src, 0})
+		}
+		if it.len > 0 {
+			f = append(f, Piece{it.src, it.src + it.len, it.dst - it.src})
+		}
+		lo = it.src + it.len
+	}
+	if lo < math.MaxInt {
+		f = append(f, Piece{lo, math.MaxInt, 0})
 	}
 
-	return src, m[i+1].src - src
+	return f.merge(), nil
 }
 
-func traverce(i int, maps []Map, src, n int) int {
-	m := maps[i]
-	minimum := math.MaxInt
+// merge joins the adjacent pieces with the same shift
+func (f Mapping) merge() Mapping {
+	res := f[:0]
+	for _, p := range f {
+		if k := len(res) - 1; k >= 0 && res[k].hi == p.lo && res[k].off == p.off {
+			res[k].hi = p.hi
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
 
-	for end := src + n; src < end; {
-		src2, n2 := m.Get(src)
-		n2 = min(n2, end-src)
-		src += n2
+// Get returns the image of the number
+func (f Mapping) Get(x int) int {
+	i := sort.Search(len(f), func(i int) bool {
+		return f[i].hi > x
+	})
+	return x + f[i].off
+}
 
-		var v int
-		if i+1 == len(maps) {
-			v = src2
-		} else {
-			v = traverce(i+1, maps, src2, n2)
+// Then returns the composition: g applied after f
+func (f Mapping) Then(g Mapping) Mapping {
+	var res Mapping
+	for _, p := range f {
+		// split the image [lo+off, hi+off) by the pieces of g
+		lo, hi := p.lo+p.off, p.hi+p.off
+		k := sort.Search(len(g), func(k int) bool {
+			return g[k].hi > lo
+		})
+		for ; lo < hi; k++ {
+			end := min(hi, g[k].hi)
+			res = append(res, Piece{lo - p.off, end - p.off, p.off + g[k].off})
+			lo = end
 		}
+	}
+	return res.merge()
+}
 
-		minimum = min(minimum, v)
+// Apply returns the pieces of the mapping restricted to [lo, hi)
+func (f Mapping) Apply(lo, hi int) Mapping {
+	var res Mapping
+	i := sort.Search(len(f), func(i int) bool {
+		return f[i].hi > lo
+	})
+	for ; i < len(f) && f[i].lo < hi; i++ {
+		res = append(res, Piece{max(lo, f[i].lo), min(hi, f[i].hi), f[i].off})
 	}
+	return res
+}
 
-	return minimum
+// Inverse returns all numbers mapped to y
+func (f Mapping) Inverse(y int) []int {
+	var res []int
+	for _, p := range f {
+		if x := y - p.off; p.lo <= x && x < p.hi {
+			res = append(res, x)
+		}
+	}
+	sort.Ints(res)
+	return res
 }
 
 func ScanMap(scan *Scanner) (Map, error) {
@@ -86,61 +147,246 @@ func ScanMap(scan *Scanner) (Map, error) {
 	return m, err
 }
 
-func _run(scan *Scanner, bw *bufio.Writer) error {
+// Edge is the map from one category to another
+type Edge struct {
+	to      string
+	mapping Mapping
+}
+
+// Almanac is the graph of the categories
+type Almanac struct {
+	seeds []int
+	edges map[string][]Edge
+}
+
+// parseHeader parses `X-to-Y`
+func parseHeader(text string) (string, string, bool) {
+	from, to, ok := strings.Cut(text, "-to-")
+	return from, to, ok && from != "" && to != ""
+}
+
+func ReadAlmanac(scan *Scanner) (*Almanac, error) {
 	var err error
 
 	// skip: `seeds:`
-	scan.Scan()
+	if !scan.Scan() || scan.Text() != "seeds:" {
+		return nil, fmt.Errorf("seeds not found")
+	}
 
-	var seeds [][2]int
+	a := &Almanac{edges: make(map[string][]Edge)}
 	for {
-		var a, b int
-		a, b, err = scan.TwoInt()
+		var v int
+		v, err = scan.Int()
 		if err != nil {
 			break
 		}
-		seeds = append(seeds, [2]int{a, b})
+		a.seeds = append(a.seeds, v)
 	}
 
-	if debugEnable {
-		log.Println("seeds:", seeds)
+	for err != io.EOF {
+		from, to, ok := parseHeader(scan.Text())
+		if !ok {
+			return nil, fmt.Errorf("unexpected %q, want X-to-Y map header", scan.Text())
+		}
+
+		for _, e := range a.edges[from] {
+			if e.to == to {
+				return nil, fmt.Errorf("duplicated map %s-to-%s", from, to)
+			}
+		}
+
+		var m Map
+		m, err = ScanMap(scan)
+		f, mapErr := NewMapping(m)
+		if mapErr != nil {
+			return nil, fmt.Errorf("%s-to-%s: %w", from, to, mapErr)
+		}
+
+		a.edges[from] = append(a.edges[from], Edge{to, f})
 	}
 
-	maps := make([]Map, 7)
+	if err := a.checkCycles(); err != nil {
+		return nil, err
+	}
 
-	for err != io.EOF {
-		switch scan.Text() {
-		case "seed-to-soil":
-			maps[0], err = ScanMap(scan)
-		case "soil-to-fertilizer":
-			maps[1], err = ScanMap(scan)
-		case "fertilizer-to-water":
-			maps[2], err = ScanMap(scan)
-		case "water-to-light":
-			maps[3], err = ScanMap(scan)
-		case "light-to-temperature":
-			maps[4], err = ScanMap(scan)
-		case "temperature-to-humidity":
-			maps[5], err = ScanMap(scan)
-		case "humidity-to-location":
-			maps[6], err = ScanMap(scan)
-		default:
+	return a, nil
+}
+
+// checkCycles returns an error if the categories have a cycle
+func (a *Almanac) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(a.edges))
+
+	var visit func(c string) error
+	visit = func(c string) error {
+		switch state[c] {
+		case visiting:
+			return fmt.Errorf("categories have a cycle through %s", c)
+		case done:
+			return nil
+		}
+
+		state[c] = visiting
+		for _, e := range a.edges[c] {
+			if err := visit(e.to); err != nil {
+				return err
+			}
+		}
+		state[c] = done
+		return nil
+	}
+
+	for c := range a.edges {
+		if err := visit(c); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// Chain returns the composition of the maps from one category to another. The way
+// between them must exist and be unique
+func (a *Almanac) Chain(from, to string) (Mapping, error) {
+	var ways []Mapping
+
+	var visit func(c string, f Mapping)
+	visit = func(c string, f Mapping) {
+		if c == to {
+			ways = append(ways, f)
+			return
+		}
+		for _, e := range a.edges[c] {
+			visit(e.to, f.Then(e.mapping))
+		}
+	}
+	visit(from, Mapping{{0, math.MaxInt, 0}})
+
+	switch len(ways) {
+	case 0:
+		return nil, fmt.Errorf("no maps from %s to %s", from, to)
+	case 1:
+		return ways[0], nil
+	default:
+		return nil, fmt.Errorf("%d ways from %s to %s", len(ways), from, to)
+	}
+}
+
+var (
+	printTable   = false
+	valueQuery   = -1
+	fromCategory = "seed"
+	toCategory   = "location"
+)
+
+// seedRanges returns the ranges of the category which the seed ranges are mapped to
+func (a *Almanac) seedRanges(category string) ([]Piece, error) {
+	f, err := a.Chain("seed", category)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []Piece
+	for i := 0; i+1 < len(a.seeds); i += 2 {
+		for _, p := range f.Apply(a.seeds[i], a.seeds[i]+a.seeds[i+1]) {
+			ranges = append(ranges, Piece{p.lo + p.off, p.hi + p.off, 0})
+		}
+	}
+	return ranges, nil
+}
+
+// writeTable writes the ranges split into the pieces of the mapping
+func writeTable(w io.Writer, pipeline Mapping, ranges []Piece) {
+	for _, r := range ranges {
+		for _, p := range pipeline.Apply(r.lo, r.hi) {
+			fmt.Fprintf(w, "%s [%d, %d) -> %s [%d, %d)\n", fromCategory, p.lo, p.hi, toCategory, p.lo+p.off, p.hi+p.off)
+		}
+	}
+}
+
+// writeInverse writes the numbers mapped to the value. For the seeds it writes whether
+// they are the seeds of the almanac for the first and the second part
+func writeInverse(w io.Writer, pipeline Mapping, seeds []int, val int) {
+	found := pipeline.Inverse(val)
+	if len(found) == 0 {
+		fmt.Fprintf(w, "%s %d: no %s\n", toCategory, val, fromCategory)
+		return
+	}
+
+	for _, x := range found {
+		fmt.Fprintf(w, "%s %d: %s %d", toCategory, val, fromCategory, x)
+		if fromCategory == "seed" {
+			for _, v := range seeds {
+				if v == x {
+					fmt.Fprint(w, " (part 1)")
+					break
+				}
+			}
+			for i := 0; i+1 < len(seeds); i += 2 {
+				if seeds[i] <= x && x < seeds[i]+seeds[i+1] {
+					fmt.Fprint(w, " (part 2)")
+					break
+				}
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func _run(scan *Scanner, bw *bufio.Writer) error {
+	a, err := ReadAlmanac(scan)
+	if err != nil {
+		return err
+	}
 
 	if debugEnable {
-		log.Println("maps:", maps)
+		log.Println("seeds:", a.seeds)
+		log.Println("maps:", a.edges)
+	}
+
+	pipeline, err := a.Chain(fromCategory, toCategory)
+	if err != nil {
+		return err
+	}
+
+	if debugEnable {
+		log.Println("pipeline:", pipeline)
+	}
+
+	if printTable {
+		ranges, err := a.seedRanges(fromCategory)
+		if err != nil {
+			return err
+		}
+		writeTable(bw, pipeline, ranges)
+	}
+	if valueQuery >= 0 {
+		writeInverse(bw, pipeline, a.seeds, valueQuery)
 	}
 
-	minimum := math.MaxInt
+	seedPipeline, err := a.Chain("seed", toCategory)
+	if err != nil {
+		return err
+	}
 
-	for i := range seeds {
-		v := traverce(0, maps, seeds[i][0], seeds[i][1])
-		minimum = min(minimum, v)
+	// part 1: the seeds are numbers
+	minimum1 := math.MaxInt
+	for _, v := range a.seeds {
+		minimum1 = min(minimum1, seedPipeline.Get(v))
 	}
 
-	fmt.Fprintln(bw, minimum)
+	// part 2: the seeds are ranges, the minimum of a piece is at its beginning
+	minimum2 := math.MaxInt
+	for i := 0; i+1 < len(a.seeds); i += 2 {
+		for _, p := range seedPipeline.Apply(a.seeds[i], a.seeds[i]+a.seeds[i+1]) {
+			minimum2 = min(minimum2, p.lo+p.off)
+		}
+	}
+
+	fmt.Fprintln(bw, minimum1)
+	fmt.Fprintln(bw, minimum2)
 	return nil
 }
 
@@ -151,6 +397,13 @@ func min(a, b int) int {
 	return a
 }
 
+func max(a, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
+
 func run(r io.Reader, w io.Writer) (err error) {
 	sc := NewScanner(r)
 	bw := bufio.NewWriter(w)
@@ -165,6 +418,13 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&fromCategory, "from", fromCategory, "source category")
+	flag.StringVar(&toCategory, "to", toCategory, "destination category")
+	flag.BoolVar(&printTable, "table", printTable, "print the seed ranges in the source category with their destination ranges")
+	flag.IntVar(&valueQuery, "value", valueQuery, "print the source numbers which end up at the destination value")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
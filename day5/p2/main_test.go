@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"io"
+	"math"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -53,7 +55,8 @@ temperature-to-humidity map:
 humidity-to-location map:
 60 56 37
 56 93 4`)},
-			`46`,
+			`35
+46`,
 			false,
 			true,
 		},
@@ -88,3 +91,243 @@ humidity-to-location map:
 		})
 	}
 }
+
+const example = `seeds: 79 14 55 13
+
+seed-to-soil map:
+50 98 2
+52 50 48
+
+soil-to-fertilizer map:
+0 15 37
+37 52 2
+39 0 15
+
+fertilizer-to-water map:
+49 53 8
+0 11 42
+42 0 7
+57 7 4
+
+water-to-light map:
+88 18 7
+18 25 70
+
+light-to-temperature map:
+45 77 23
+81 45 19
+68 64 13
+
+temperature-to-humidity map:
+0 69 1
+1 0 69
+
+humidity-to-location map:
+60 56 37
+56 93 4`
+
+func Test_run_queries(t *testing.T) {
+	tests := []struct {
+		name  string
+		table bool
+		value int
+		wantW string
+	}{
+		{
+			"table",
+			true,
+			-1,
+			`seed [79, 82) -> location [82, 85)
+seed [82, 92) -> location [46, 56)
+seed [92, 93) -> location [60, 61)
+seed [55, 59) -> location [86, 90)
+seed [59, 62) -> location [94, 97)
+seed [62, 66) -> location [56, 60)
+seed [66, 68) -> location [97, 99)
+35
+46`,
+		},
+		{"location 46", false, 46, "location 46: seed 82 (part 2)\n35\n46"},
+		{"location 82", false, 82, "location 82: seed 79 (part 1) (part 2)\n35\n46"},
+		{"location 5", false, 5, "location 5: seed 30\n35\n46"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printTable = tt.table
+			valueQuery = tt.value
+			defer func() { printTable = false; valueQuery = -1 }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(example), w); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
+
+func TestMapping_Then(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+
+	randomMap := func() Map {
+		var m Map
+		src := rnd.Intn(5)
+		for k := rnd.Intn(5); k > 0; k-- {
+			it := MapItem{dst: rnd.Intn(100), src: src, len: rnd.Intn(20)}
+			m = append(m, it)
+			src += it.len + rnd.Intn(5)
+		}
+		return m
+	}
+
+	for n := 0; n < 100; n++ {
+		maps := []Map{randomMap(), randomMap(), randomMap()}
+		pipeline := Mapping{{0, math.MaxInt, 0}}
+		for _, m := range maps {
+			f, err := NewMapping(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pipeline = pipeline.Then(f)
+		}
+
+		for x := 0; x < 150; x++ {
+			y := x
+			for _, m := range maps {
+				for _, it := range m {
+					if it.src <= y && y < it.src+it.len {
+						y = it.dst + y - it.src
+						break
+					}
+				}
+			}
+
+			if got := pipeline.Get(x); got != y {
+				t.Fatalf("maps %v: Get(%d) = %d, want %d", maps, x, got, y)
+			}
+
+			found := false
+			for _, v := range pipeline.Inverse(y) {
+				found = found || v == x
+			}
+			if !found {
+				t.Fatalf("maps %v: Inverse(%d) = %v, want %d in it", maps, y, pipeline.Inverse(y), x)
+			}
+		}
+	}
+}
+
+func Test_run_categories(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{
+			"soil to humidity",
+			"soil",
+			"humidity",
+			example,
+			`soil [81, 84) -> humidity [78, 81)
+soil [84, 95) -> humidity [46, 57)
+soil [57, 61) -> humidity [82, 86)
+soil [61, 70) -> humidity [90, 99)
+35
+46`,
+			false,
+		},
+		{
+			"dag",
+			"seed",
+			"location",
+			`seeds: 1 2
+			seed-to-soil map:
+			10 1 1
+			seed-to-color map:
+			soil-to-location map:
+			20 10 1`,
+			"seed [1, 2) -> location [20, 21)\nseed [2, 3) -> location [2, 3)\n2\n2",
+			false,
+		},
+		{
+			"shuffled",
+			"seed",
+			"location",
+			`seeds: 1 2
+			soil-to-location map:
+			20 10 1
+			seed-to-soil map:
+			10 1 1`,
+			"seed [1, 2) -> location [20, 21)\nseed [2, 3) -> location [2, 3)\n2\n2",
+			false,
+		},
+		{
+			"gap",
+			"seed",
+			"location",
+			`seeds: 1 2
+			seed-to-soil map:
+			fertilizer-to-location map:`,
+			``,
+			true,
+		},
+		{
+			"ambiguous",
+			"seed",
+			"location",
+			`seeds: 1 2
+			seed-to-soil map:
+			seed-to-water map:
+			soil-to-location map:
+			water-to-location map:`,
+			``,
+			true,
+		},
+		{
+			"cycle",
+			"seed",
+			"location",
+			`seeds: 1 2
+			seed-to-soil map:
+			soil-to-seed map:
+			soil-to-location map:`,
+			``,
+			true,
+		},
+		{
+			"duplicated",
+			"seed",
+			"location",
+			`seeds: 1 2
+			seed-to-location map:
+			seed-to-location map:`,
+			``,
+			true,
+		},
+		{"bad header", "seed", "location", "seeds: 1 2\nseed-location map:", ``, true},
+		{"overlapped", "seed", "location", "seeds: 1 2\nseed-to-location map:\n1 1 5\n2 3 1", ``, true},
+		{"unknown category", "soil", "sun", example, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromCategory = tt.from
+			toCategory = tt.to
+			printTable = true
+			defer func() { fromCategory = "seed"; toCategory = "location"; printTable = false }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) && !tt.wantErr {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
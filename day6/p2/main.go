@@ -2,65 +2,145 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"math/big"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"unsafe"
 )
 
-func solution(t, s int) int {
-	xm := t / 2
+// Window is the hold times [lo, hi] which win the race
+type Window struct {
+	lo, hi *big.Int
+}
 
-	x1 := sort.Search(xm+1, func(x int) bool {
-		return (t-x)*x-s > 0
-	})
+// Count returns the number of the winning hold times
+func (w Window) Count() *big.Int {
+	if w.lo == nil {
+		return new(big.Int)
+	}
+	n := new(big.Int).Sub(w.hi, w.lo)
+	return n.Add(n, big.NewInt(1))
+}
 
-	xm2 := xm + 1
-	x2 := sort.Search(t-xm2+1, func(x int) bool {
-		return (t-(x+xm2))*(x+xm2)-s <= 0
-	}) + xm2
+func (w Window) String() string {
+	if w.lo == nil {
+		return "none"
+	}
+	return w.lo.String() + ".." + w.hi.String()
+}
+
+// wins reports whether holding the button x ms beats the record s in the race of t ms
+func wins(t, s, x *big.Int) bool {
+	d := new(big.Int).Sub(t, x)
+	d.Mul(d, x)
+	return d.Cmp(s) > 0
+}
+
+// solution returns the winning window of the race. The distance (t-x)*x beats s
+// between the roots of x^2 - t*x + s = 0, that is (t ± sqrt(t^2-4s))/2
+func solution(t, s *big.Int) Window {
+	one := big.NewInt(1)
+
+	disc := new(big.Int).Mul(t, t)
+	disc.Sub(disc, new(big.Int).Lsh(s, 2))
+	if disc.Sign() <= 0 {
+		return Window{}
+	}
+
+	// the first integer after the smaller root, isqrt is off by less than one
+	x1 := new(big.Int).Sqrt(disc)
+	x1.Sub(t, x1)
+	x1.Rsh(x1, 1)
+	if x1.Sign() < 0 {
+		x1.SetInt64(0)
+	}
+	for k := 0; k < 2 && !wins(t, s, x1); k++ {
+		x1.Add(x1, one)
+	}
+	for prev := new(big.Int).Sub(x1, one); prev.Sign() >= 0 && wins(t, s, prev); prev.Sub(prev, one) {
+		x1.Set(prev)
+	}
+
+	if x1.Cmp(t) > 0 || !wins(t, s, x1) {
+		return Window{}
+	}
+
+	// the distance is symmetric: (t-x)*x
+	x2 := new(big.Int).Sub(t, x1)
+	if x2.Cmp(x1) < 0 {
+		return Window{}
+	}
 
 	if debugEnable {
-		log.Printf("%d, %d: x1=%d, x2=%d", t, s, x1, x2)
+		log.Printf("%v, %v: x1=%v, x2=%v", t, s, x1, x2)
 	}
 
-	return x2 - x1
+	return Window{x1, x2}
 }
 
-func _run(scan *Scanner, bw *bufio.Writer) error {
-	var sb strings.Builder
-
-	// skip: Time:
-	scan.Scan()
+var kerning = true
 
+// scanNumbers reads the numbers until the word stop (or EOF if empty). With the
+// kerning the numbers are joined into one
+func scanNumbers(scan *Scanner, stop string) ([]*big.Int, error) {
+	var words []string
 	for scan.Scan() {
-		if scan.Text() == "Distance:" {
+		if stop != "" && scan.Text() == stop {
 			break
 		}
-		sb.WriteString(scan.Text())
+		words = append(words, scan.Text())
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
 	}
 
-	t, err := strconv.Atoi(sb.String())
-	if err != nil {
-		return fmt.Errorf("can't parse t: %w", err)
+	if kerning {
+		words = []string{strings.Join(words, "")}
 	}
-	sb.Reset()
 
-	for scan.Scan() {
-		sb.WriteString(scan.Text())
+	nums := make([]*big.Int, len(words))
+	for i, w := range words {
+		v, ok := new(big.Int).SetString(w, 10)
+		if !ok {
+			return nil, fmt.Errorf("can't parse number %q", w)
+		}
+		nums[i] = v
+	}
+	return nums, nil
+}
+
+func _run(scan *Scanner, bw *bufio.Writer) error {
+	if !scan.Scan() || scan.Text() != "Time:" {
+		return fmt.Errorf("time: not found")
+	}
+
+	t, err := scanNumbers(scan, "Distance:")
+	if err != nil {
+		return fmt.Errorf("can't parse t: %w", err)
 	}
 
-	s, err := strconv.Atoi(sb.String())
+	s, err := scanNumbers(scan, "")
 	if err != nil {
 		return fmt.Errorf("can't parse s: %w", err)
 	}
 
-	res := solution(t, s)
+	if len(t) != len(s) {
+		return fmt.Errorf("%d times and %d distances", len(t), len(s))
+	}
+
+	res := big.NewInt(1)
+	for i := range t {
+		win := solution(t[i], s[i])
+		count := win.Count()
+		fmt.Fprintf(bw, "race %d: time %v, distance %v, hold %v, ways %v\n", i+1, t[i], s[i], win, count)
+		res.Mul(res, count)
+	}
 
 	fmt.Fprintln(bw, res)
 	return nil
@@ -80,6 +160,20 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.Func("kerning", "on: join the numbers into one race (part 2), off: separate races (part 1)", func(s string) error {
+		switch s {
+		case "on":
+			kerning = true
+		case "off":
+			kerning = false
+		default:
+			return fmt.Errorf("want on or off")
+		}
+		return nil
+	})
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
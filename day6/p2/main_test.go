@@ -22,7 +22,8 @@ func Test_run(t *testing.T) {
 			"1",
 			args{strings.NewReader(`Time:      7  15   30
 Distance:  9  40  200`)},
-			`71503`,
+			`race 1: time 71530, distance 940200, hold 14..71516, ways 71503
+71503`,
 			false,
 			true,
 		},
@@ -57,3 +58,59 @@ Distance:  9  40  200`)},
 		})
 	}
 }
+
+func Test_run_kerning(t *testing.T) {
+	tests := []struct {
+		name    string
+		kerning bool
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{
+			"off",
+			false,
+			"Time:      7  15   30\nDistance:  9  40  200",
+			`race 1: time 7, distance 9, hold 2..5, ways 4
+race 2: time 15, distance 40, hold 4..11, ways 8
+race 3: time 30, distance 200, hold 11..19, ways 9
+288`,
+			false,
+		},
+		{
+			"huge",
+			true,
+			"Time: 10000000000 0000000000\nDistance: 0",
+			`race 1: time 100000000000000000000, distance 0, hold 1..99999999999999999999, ways 99999999999999999999
+99999999999999999999`,
+			false,
+		},
+		{
+			"no ways",
+			false,
+			"Time: 5 4\nDistance: 6 4",
+			`race 1: time 5, distance 6, hold none, ways 0
+race 2: time 4, distance 4, hold none, ways 0
+0`,
+			false,
+		},
+		{"mismatch", false, "Time: 5 4\nDistance: 6", ``, true},
+		{"bad number", false, "Time: 5 x\nDistance: 6 4", ``, true},
+		{"no time", false, "Distance: 6 4", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kerning = tt.kerning
+			defer func() { kerning = true }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) && !tt.wantErr {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package camel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type HandType int
+
+//go:generate stringer -type=HandType
+const (
+	_           HandType = 7 - iota
+	FiveOfKind           // where all five cards have the same label: AAAAA
+	FourOfKind           // where four cards have the same label and one card has a different label: AA8AA
+	FullHouse            // where three cards have the same label, and the remaining two cards share a different label: 23332
+	ThreeOfKind          // where three cards have the same label, and the remaining two cards are each different from any other card in the hand: TTT98
+	TwoPair              // where two cards share one label, two other cards share a second label, and the remaining card has a third label: 23432
+	OnePair              // where two cards share one label, and the other three cards have a different label from the pair and each other: A23A4
+	HighCard             // where all cards' labels are distinct: 23456
+)
+
+// TieBreak is the way to order hands of the same type
+type TieBreak string
+
+const (
+	// ByPosition compares the cards one by one from the first card (the puzzle rule)
+	ByPosition TieBreak = "position"
+	// ByGroups compares the cards sorted by the group size and then by the strength (the
+	// poker rule, e.g. the pair first and then the kickers)
+	ByGroups TieBreak = "groups"
+)
+
+// Rules is the configuration of the game
+type Rules struct {
+	// Order is the card labels from the strongest to the weakest
+	Order string `json:"order"`
+	// Wildcards is the labels which act like whatever card would make the hand the
+	// strongest type. Their strength for the tie-break is defined by Order
+	Wildcards string `json:"wildcards"`
+	// TieBreak is the way to order hands of the same type, ByPosition if empty
+	TieBreak TieBreak `json:"tieBreak"`
+}
+
+var (
+	Standard = Rules{Order: "AKQJT98765432", TieBreak: ByPosition}
+	Joker    = Rules{Order: "AKQT98765432J", Wildcards: "J", TieBreak: ByPosition}
+)
+
+// Validate checks the labels are unique and the wildcards are in the order
+func (r *Rules) Validate() error {
+	if r.Order == "" {
+		return errors.New("rules: empty card order")
+	}
+	for i := 0; i < len(r.Order); i++ {
+		if strings.IndexByte(r.Order[i+1:], r.Order[i]) != -1 {
+			return fmt.Errorf("rules: duplicated card %q in order %q", r.Order[i], r.Order)
+		}
+	}
+	for i := 0; i < len(r.Wildcards); i++ {
+		if strings.IndexByte(r.Order, r.Wildcards[i]) == -1 {
+			return fmt.Errorf("rules: wildcard %q is not in order %q", r.Wildcards[i], r.Order)
+		}
+	}
+	switch r.TieBreak {
+	case "":
+		r.TieBreak = ByPosition
+	case ByPosition, ByGroups:
+	default:
+		return fmt.Errorf("rules: unknown tie-break %q", r.TieBreak)
+	}
+	return nil
+}
+
+// LoadRules returns the rules by the name (standard or joker) or reads them from the
+// JSON file
+func LoadRules(name string) (Rules, error) {
+	switch name {
+	case "standard":
+		return Standard, nil
+	case "joker":
+		return Joker, nil
+	}
+
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(buf, &rules); err != nil {
+		return Rules{}, fmt.Errorf("rules %s: %w", name, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return Rules{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return rules, nil
+}
+
+const handSize = 5
+
+type Hand struct {
+	Raw  string
+	Type HandType
+	Bid  int
+	keys []int // card strengths for the tie-break
+}
+
+func (h Hand) String() string {
+	return fmt.Sprintf("{%s %v %d}", h.Raw, h.Type, h.Bid)
+}
+
+// NewHand returns the hand evaluated by the rules
+func (r *Rules) NewHand(raw string, bid int) (*Hand, error) {
+	if len(raw) != handSize {
+		return nil, fmt.Errorf("hand %q: want %d cards", raw, handSize)
+	}
+
+	strength := make([]int, len(raw))
+	count := make(map[byte]int, handSize)
+	wild := 0
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		k := strings.IndexByte(r.Order, c)
+		if k == -1 {
+			return nil, fmt.Errorf("hand %q: unknown card %q", raw, c)
+		}
+		strength[i] = len(r.Order) - k
+
+		if strings.IndexByte(r.Wildcards, c) != -1 {
+			wild++
+		} else {
+			count[c]++
+		}
+	}
+
+	groups := make([]int, 0, len(count))
+	for _, n := range count {
+		groups = append(groups, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(groups)))
+
+	// the wildcards always join the largest group
+	if len(groups) == 0 {
+		groups = append(groups, 0)
+	}
+	groups[0] += wild
+
+	keys := strength
+	if r.TieBreak == ByGroups {
+		keys = append([]int(nil), strength...)
+		groupOf := func(s int) int {
+			return count[r.Order[len(r.Order)-s]]
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			gi, gj := groupOf(keys[i]), groupOf(keys[j])
+			return gi > gj || gi == gj && keys[i] > keys[j]
+		})
+	}
+
+	return &Hand{
+		Raw:  raw,
+		Type: calcHandType(groups),
+		Bid:  bid,
+		keys: keys,
+	}, nil
+}
+
+// calcHandType returns the type by the group sizes sorted in descending order
+func calcHandType(groups []int) HandType {
+	switch {
+	case groups[0] == 5:
+		return FiveOfKind
+	case groups[0] == 4:
+		return FourOfKind
+	case groups[0] == 3 && groups[1] == 2:
+		return FullHouse
+	case groups[0] == 3:
+		return ThreeOfKind
+	case groups[0] == 2 && groups[1] == 2:
+		return TwoPair
+	case groups[0] == 2:
+		return OnePair
+	default:
+		return HighCard
+	}
+}
+
+func (h *Hand) Less(other *Hand) bool {
+	if h.Type != other.Type {
+		return h.Type < other.Type
+	}
+	for i := range h.keys {
+		if h.keys[i] != other.keys[i] {
+			return h.keys[i] < other.keys[i]
+		}
+	}
+	return false
+}
+
+// ReadHands reads the lines "hand bid"
+func (r *Rules) ReadHands(sc interface {
+	Scan() bool
+	Text() string
+	Err() error
+}) ([]*Hand, error) {
+	var hands []*Hand
+
+	for sc.Scan() {
+		raw := sc.Text()
+		if !sc.Scan() {
+			return nil, fmt.Errorf("hand %q: no bid", raw)
+		}
+		bid, err := strconv.Atoi(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("hand %q: %w", raw, err)
+		}
+
+		h, err := r.NewHand(raw, bid)
+		if err != nil {
+			return nil, err
+		}
+		hands = append(hands, h)
+	}
+
+	return hands, sc.Err()
+}
+
+// Rank sorts the hands from the weakest to the strongest one and returns the total
+// winnings
+func Rank(hands []*Hand) int {
+	sort.SliceStable(hands, func(i, j int) bool {
+		return hands[i].Less(hands[j])
+	})
+
+	total := 0
+	for i, h := range hands {
+		total += (i + 1) * h.Bid
+	}
+	return total
+}
+
+// WriteRanked writes the ranked hands with their types and winnings
+func WriteRanked(w io.Writer, hands []*Hand) error {
+	if _, err := fmt.Fprintf(w, "%5s %-5s %-11s %5s %9s\n", "rank", "hand", "type", "bid", "winnings"); err != nil {
+		return err
+	}
+	for i, h := range hands {
+		rank := i + 1
+		if _, err := fmt.Fprintf(w, "%5d %-5s %-11v %5d %9d\n", rank, h.Raw, h.Type, h.Bid, rank*h.Bid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,6 @@
 // Code generated by "stringer -type=HandType"; DO NOT EDIT.
 
-package main
+package camel
 
 import "strconv"
 
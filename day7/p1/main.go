@@ -1,138 +1,42 @@
 package main
 
 import (
+	"adventofcode-2023/day7/camel"
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
-	"sort"
 	"strconv"
 	"unsafe"
 )
 
-type HandType int
-
-//go:generate stringer -type=HandType
-const (
-	_           HandType = 7 - iota
-	FiveOfKind           // where all five cards have the same label: AAAAA
-	FourOfKind           // where four cards have the same label and one card has a different label: AA8AA
-	FullHouse            // where three cards have the same label, and the remaining two cards share a different label: 23332
-	ThreeOfKind          // where three cards have the same label, and the remaining two cards are each different from any other card in the hand: TTT98
-	TwoPair              // where two cards share one label, two other cards share a second label, and the remaining card has a third label: 23432
-	OnePair              // where two cards share one label, and the other three cards have a different label from the pair and each other: A23A4
-	HighCard             // where all cards' labels are distinct: 23456
-)
-
-type Hand struct {
-	Raw  string
-	Val  int
-	Type HandType
-}
-
-func (h Hand) String() string {
-	return fmt.Sprintf("{%s %05x %v}", h.Raw, h.Val, h.Type)
-}
-
-func NewHand(b string) *Hand {
-	v := 0
-	for i := 0; i < 5; i++ {
-		shift := (4-i)*4
-		// A, K, Q, J, T, 9, 8, 7, 6, 5, 4, 3, or 2.
-		switch c := b[i]; {
-		case c == 'A':
-			v += 14 << shift
-		case c == 'K':
-			v += 13 << shift
-		case c == 'Q':
-			v += 12 << shift
-		case c == 'J':
-			v += 11 << shift
-		case c == 'T':
-			v += 10 << shift
-		case '2' <= c && c <= '9':
-			v += int(c-'0') << shift
-		}
-	}
-	return &Hand{
-		Raw:  b,
-		Val:  v,
-		Type: calcHandType(v),
-	}
-}
-
-func calcHandType(v int) HandType {
-	f1 := make([]int, 15)
-
-	for i := 0; i < 5; i++ {
-		f1[(v>>(i*4))&15]++
-	}
-
-	f2 := make([]int, 6)
-	for _, v := range f1 {
-		f2[v]++
-	}
-
-	switch {
-	case f2[5] == 1:
-		return FiveOfKind
-	case f2[4] == 1:
-		return FourOfKind
-	case f2[3] == 1 && f2[2] == 1:
-		return FullHouse
-	case f2[3] == 1:
-		return ThreeOfKind
-	case f2[2] == 2:
-		return TwoPair
-	case f2[2] == 1:
-		return OnePair
-	default:
-		return HighCard
-	}
-}
-
-func (h *Hand) Less(other *Hand) bool {
-	return h.Type < other.Type || h.Type == other.Type && h.Val < other.Val
-}
-
-type HandBid struct {
-	Hand *Hand
-	Bid  int
-}
-
-// func calcTotalWinning(hands []HandBid) int {
-
-// }
+var rulesName = "standard"
 
 func _run(scan *Scanner, bw *bufio.Writer) error {
-	var hands []HandBid
-
-	for scan.Scan() {
-		h := NewHand(scan.Text())
-		b, err := scan.Int()
-		if err != nil {
-			return err
-		}
-		hands = append(hands, HandBid{Hand: h, Bid: b})
+	rules, err := camel.LoadRules(rulesName)
+	if err != nil {
+		return err
 	}
 
-	if err := scan.Err(); err != nil {
+	hands, err := rules.ReadHands(scan)
+	if err != nil {
 		return err
 	}
 
-	sort.Slice(hands, func(i, j int) bool {
-		return hands[i].Hand.Less(hands[j].Hand)
-	})
+	total := camel.Rank(hands)
 
 	if debugEnable {
-		log.Println("hands:", hands)
+		log.Println("hands:")
+		for _, h := range hands {
+			log.Println(h)
+		}
 	}
 
-	total := 0
-	for i, h := range hands {
-		total += (i+1)*h.Bid
+	if err := camel.WriteRanked(bw, hands); err != nil {
+		return err
 	}
 
 	fmt.Fprintln(bw, total)
@@ -153,6 +57,10 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.StringVar(&rulesName, "rules", rulesName, "rules: standard, joker or a JSON file with order, wildcards and tieBreak")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -25,7 +25,13 @@ func Test_run(t *testing.T) {
 			KK677 28
 			KTJJT 220
 			QQQJA 483`)},
-			`6440`,
+			` rank hand  type          bid  winnings
+    1 32T3K OnePair       765       765
+    2 KTJJT TwoPair       220       440
+    3 KK677 TwoPair        28        84
+    4 T55J5 ThreeOfKind   684      2736
+    5 QQQJA ThreeOfKind   483      2415
+6440`,
 			false,
 			true,
 		},
@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -25,7 +27,13 @@ func Test_run(t *testing.T) {
 			KK677 28
 			KTJJT 220
 			QQQJA 483`)},
-			`5905`,
+			` rank hand  type          bid  winnings
+    1 32T3K OnePair       765       765
+    2 KK677 TwoPair        28        56
+    3 T55J5 FourOfKind    684      2052
+    4 QQQJA FourOfKind    483      1932
+    5 KTJJT FourOfKind    220      1100
+5905`,
 			false,
 			true,
 		},
@@ -60,3 +68,80 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_rules(t *testing.T) {
+	dir := t.TempDir()
+	writeRules := func(name, text string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+
+	groups := writeRules("groups.json", `{"order": "AKQT98765432J", "wildcards": "J", "tieBreak": "groups"}`)
+	badWild := writeRules("bad-wild.json", `{"order": "AKQT98765432", "wildcards": "J"}`)
+	badJSON := writeRules("bad.json", `{"order": `)
+
+	tests := []struct {
+		name    string
+		rules   string
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{
+			"standard",
+			"standard",
+			"2AAKK 1\nAKK22 2\nJ2345 3",
+			` rank hand  type          bid  winnings
+    1 J2345 HighCard        3         3
+    2 2AAKK TwoPair         1         2
+    3 AKK22 TwoPair         2         6
+11`,
+			false,
+		},
+		{
+			"groups",
+			groups,
+			"2AAKK 1\nAKK22 2\nJ2345 3",
+			` rank hand  type          bid  winnings
+    1 J2345 OnePair         3         3
+    2 AKK22 TwoPair         2         4
+    3 2AAKK TwoPair         1         3
+10`,
+			false,
+		},
+		{
+			"all jokers",
+			"joker",
+			"JJJJJ 7\nAAAAK 1",
+			` rank hand  type          bid  winnings
+    1 AAAAK FourOfKind      1         1
+    2 JJJJJ FiveOfKind      7        14
+15`,
+			false,
+		},
+		{"unknown card", "joker", "2345X 1", ``, true},
+		{"short hand", "joker", "2345 1", ``, true},
+		{"no bid", "joker", "23456", ``, true},
+		{"bad wildcard", badWild, "23456 1", ``, true},
+		{"bad json", badJSON, "23456 1", ``, true},
+		{"no file", filepath.Join(dir, "none.json"), "23456 1", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesName = tt.rules
+			defer func() { rulesName = "joker" }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
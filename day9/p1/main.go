@@ -3,37 +3,101 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"unsafe"
 )
 
-func calc(nums []int) int {
-	res := 0
-	bingo := false
-	
-	for !bingo {
-		res += nums[len(nums)-1]
-		bingo = true
-		for i := 1; i < len(nums); i++ {
-			v := nums[i] - nums[i-1]
-			if v != 0 {
-				bingo = false
-			}
-			nums[i-1] = v
+// ErrNotPolynomial is returned if the differences do not reach zeros
+var ErrNotPolynomial = errors.New("not a polynomial sequence")
+
+// Newton is the Newton forward differences of a polynomial sequence: the sequence
+// value at x is the sum of C(x, j) * diffs[j]
+type Newton struct {
+	diffs []*big.Int
+}
+
+// NewNewton returns the forward differences of the sequence. The sequence is
+// polynomial if some differences are all zeros
+func NewNewton(nums []*big.Int) (*Newton, error) {
+	if len(nums) == 0 {
+		return nil, fmt.Errorf("%w: empty sequence", ErrNotPolynomial)
+	}
+
+	row := make([]*big.Int, len(nums))
+	for i, v := range nums {
+		row[i] = new(big.Int).Set(v)
+	}
+
+	var diffs []*big.Int
+	for len(row) > 0 {
+		zeros := true
+		for _, v := range row {
+			zeros = zeros && v.Sign() == 0
+		}
+		if zeros {
+			return &Newton{diffs}, nil
+		}
+
+		diffs = append(diffs, row[0])
+
+		next := make([]*big.Int, len(row)-1)
+		for k := range next {
+			next[k] = new(big.Int).Sub(row[k+1], row[k])
+		}
+		row = next
+
+		if debugEnable {
+			log.Println("   diffs:", row)
 		}
-		nums = nums[:len(nums)-1]
 	}
-	
+
+	return nil, fmt.Errorf("%w: no zero differences in %d numbers", ErrNotPolynomial, len(nums))
+}
+
+// Degree returns the degree of the polynomial, -1 for zeros
+func (nw *Newton) Degree() int {
+	return len(nw.diffs) - 1
+}
+
+// At returns the sequence value at x, x can be negative or beyond the sequence
+func (nw *Newton) At(x int64) *big.Int {
+	res := new(big.Int)
+	c := big.NewInt(1) // C(x, j)
+	term := new(big.Int)
+	for j, d := range nw.diffs {
+		if j > 0 {
+			c.Mul(c, big.NewInt(x-int64(j)+1))
+			c.Quo(c, big.NewInt(int64(j)))
+		}
+		res.Add(res, term.Mul(c, d))
+	}
 	return res
 }
 
+var (
+	stepCount   = int64(1)
+	printDegree = false
+)
+
+// extrapolate returns the value steps after the last number or before the first one
+// if steps is negative
+func extrapolate(nw *Newton, n int, steps int64) *big.Int {
+	if steps < 0 {
+		return nw.At(steps)
+	}
+	return nw.At(int64(n-1) + steps)
+}
+
 func _run(br *bufio.Reader, bw *bufio.Writer) error {
-	sum := 0
+	sum := new(big.Int)
 
 	for i := 1; ; i++ {
 		line, isPrefix, err := br.ReadLine()
@@ -47,12 +111,12 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 			return fmt.Errorf("%d: %w", i, err)
 		}
 
-		tokens := bytes.Split(line, []byte(" "))
-		nums := make([]int, 0, len(tokens))
+		tokens := bytes.Fields(line)
+		nums := make([]*big.Int, 0, len(tokens))
 		for _, token := range tokens {
-			v, err := strconv.Atoi(string(token))
-			if err != nil {
-				return fmt.Errorf("%d: %w", i, err)
+			v, ok := new(big.Int).SetString(string(token), 10)
+			if !ok {
+				return fmt.Errorf("%d: invalid number %q", i, token)
 			}
 			nums = append(nums, v)
 		}
@@ -61,7 +125,19 @@ func _run(br *bufio.Reader, bw *bufio.Writer) error {
 			log.Printf("%d: nums: %v", i, nums)
 		}
 
-		sum += calc(nums)
+		nw, err := NewNewton(nums)
+		if err != nil {
+			return fmt.Errorf("%d: %w", i, err)
+		}
+
+		v := extrapolate(nw, len(nums), stepCount)
+		if debugEnable {
+			log.Println(v)
+		}
+		if printDegree {
+			fmt.Fprintf(bw, "%d: degree %d, value %v\n", i, nw.Degree(), v)
+		}
+		sum.Add(sum, v)
 	}
 
 	fmt.Fprintln(bw, sum)
@@ -82,6 +158,11 @@ func run(r io.Reader, w io.Writer) (err error) {
 
 func main() {
 	_ = debugEnable
+
+	flag.Int64Var(&stepCount, "steps", stepCount, "number of steps to extrapolate, negative for backwards")
+	flag.BoolVar(&printDegree, "degree", printDegree, "print the polynomial degree and the value of each sequence")
+	flag.Parse()
+
 	if err := run(os.Stdin, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
@@ -58,3 +58,44 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_steps(t *testing.T) {
+	const example = `0 3 6 9 12 15
+1 3 6 10 15 21
+10 13 16 21 30 45`
+
+	tests := []struct {
+		name    string
+		steps   int64
+		degree  bool
+		input   string
+		wantW   string
+		wantErr bool
+	}{
+		{"3 ahead", 3, false, example, `215`, false},
+		{"2 behind", -2, false, example, `-10`, false},
+		{"degree", 1, true, example, "1: degree 1, value 18\n2: degree 2, value 28\n3: degree 3, value 68\n114", false},
+		{"zeros", 5, true, "0 0 0", "1: degree -1, value 0\n0", false},
+		{"huge", 4000000000, false, "1 4 9 16", `16000000032000000016`, false},
+		{"big input", 1, false, "100000000000000000000 200000000000000000000 300000000000000000000", `400000000000000000000`, false},
+		{"not polynomial", 1, false, "1 2 4 8", ``, true},
+		{"empty line", 1, false, "1 2 3\n\n4 5 6", ``, true},
+		{"bad number", 1, false, "1 2 x", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepCount = tt.steps
+			printDegree = tt.degree
+			defer func() { stepCount = 1; printDegree = false }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(tt.input), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) && !tt.wantErr {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -58,3 +58,37 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_run_steps(t *testing.T) {
+	const example = `0 3 6 9 12 15
+1 3 6 10 15 21
+10 13 16 21 30 45`
+
+	tests := []struct {
+		name    string
+		steps   int64
+		degree  bool
+		wantW   string
+		wantErr bool
+	}{
+		{"1 behind", -1, true, "1: degree 1, value -3\n2: degree 2, value 0\n3: degree 3, value 5\n2", false},
+		{"2 behind", -2, false, `-10`, false},
+		{"1 ahead", 1, false, `114`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepCount = tt.steps
+			printDegree = tt.degree
+			defer func() { stepCount = -1; printDegree = false }()
+
+			w := &bytes.Buffer{}
+			if err := run(strings.NewReader(example), w); (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotW := w.String(); strings.TrimSpace(gotW) != strings.TrimSpace(tt.wantW) {
+				t.Errorf("run() = %v, want %v", gotW, tt.wantW)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package hashmap
+
+// Entry is a key-value pair stored in a box
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// HashMap is a hash map with a fixed number of boxes. Each box keeps its entries in the
+// order of insertion, replacing a value keeps the entry position (like the HASHMAP
+// procedure from the day 15 puzzle)
+type HashMap[K comparable, V any] struct {
+	boxes [][]Entry[K, V]
+	hash  func(K) int
+	size  int
+}
+
+// New returns a hash map with boxCount boxes and the hash function. If boxCount is not
+// positive, New panics
+func New[K comparable, V any](boxCount int, hash func(K) int) *HashMap[K, V] {
+	if boxCount <= 0 {
+		panic("hashmap.New: box count must be positive")
+	}
+
+	return &HashMap[K, V]{
+		boxes: make([][]Entry[K, V], boxCount),
+		hash:  hash,
+	}
+}
+
+// BoxIndex returns the index of the box for the key
+func (m *HashMap[K, V]) BoxIndex(key K) int {
+	idx := m.hash(key) % len(m.boxes)
+	if idx < 0 {
+		idx += len(m.boxes)
+	}
+	return idx
+}
+
+func (m *HashMap[K, V]) find(key K) (int, int) {
+	box := m.BoxIndex(key)
+	for slot, e := range m.boxes[box] {
+		if e.Key == key {
+			return box, slot
+		}
+	}
+	return box, -1
+}
+
+// Get returns the value for the key
+func (m *HashMap[K, V]) Get(key K) (V, bool) {
+	box, slot := m.find(key)
+	if slot == -1 {
+		var zero V
+		return zero, false
+	}
+	return m.boxes[box][slot].Value, true
+}
+
+// Set replaces the value of the key in place or appends it to the back of its box
+func (m *HashMap[K, V]) Set(key K, val V) {
+	box, slot := m.find(key)
+	if slot == -1 {
+		m.boxes[box] = append(m.boxes[box], Entry[K, V]{key, val})
+		m.size++
+		return
+	}
+	m.boxes[box][slot].Value = val
+}
+
+// Delete removes the key from its box moving the rest entries forward. It reports
+// whether the key was present
+func (m *HashMap[K, V]) Delete(key K) bool {
+	box, slot := m.find(key)
+	if slot == -1 {
+		return false
+	}
+
+	entries := m.boxes[box]
+	copy(entries[slot:], entries[slot+1:])
+	entries[len(entries)-1] = Entry[K, V]{} // avoid memory leak
+	m.boxes[box] = entries[:len(entries)-1]
+	m.size--
+
+	return true
+}
+
+// Len returns the number of entries
+func (m *HashMap[K, V]) Len() int {
+	return m.size
+}
+
+// BoxCount returns the number of boxes
+func (m *HashMap[K, V]) BoxCount() int {
+	return len(m.boxes)
+}
+
+// Box returns the entries of the box in order. The result must not be modified
+func (m *HashMap[K, V]) Box(idx int) []Entry[K, V] {
+	return m.boxes[idx]
+}
+
+// Range calls f for each entry in order of boxes and slots in the box. If f returns
+// false, Range stops the iteration
+func (m *HashMap[K, V]) Range(f func(box, slot int, key K, val V) bool) {
+	for box, entries := range m.boxes {
+		for slot, e := range entries {
+			if !f(box, slot, e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}